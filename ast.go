@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/joerdav/brev/ast"
+	"github.com/joerdav/brev/parser"
+)
+
+const astUsage = `usage: brev ast -format=json|dot|tree [file]
+
+Ast prints the syntax tree of a Brev file, or of standard input if no file is
+given. The json format can be read back with ast.Unmarshal, dot is a Graphviz
+graph and tree is an indented listing of each node with its position.
+`
+
+// runAST implements the ast command.
+func runAST(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, astUsage)
+		flags.PrintDefaults()
+	}
+	format := flags.String("format", "", "print the tree as `json`, dot or tree")
+	asJSON := flags.Bool("json", false, "shorthand for -format=json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *asJSON {
+		if *format != "" && *format != "json" {
+			return fmt.Errorf("ast: -json conflicts with -format=%s", *format)
+		}
+		*format = "json"
+	}
+	var fprint func(io.Writer, ast.Node) error
+	switch *format {
+	case "json":
+		fprint = fprintJSON
+	case "dot":
+		fprint = ast.FprintDot
+	case "tree":
+		fprint = ast.FprintTree
+	case "":
+		flags.Usage()
+		return errors.New("ast: no output format given")
+	default:
+		flags.Usage()
+		return fmt.Errorf("ast: unknown format %q, expected json, dot or tree", *format)
+	}
+	var program *ast.Program
+	var err error
+	switch flags.NArg() {
+	case 0:
+		var src []byte
+		if src, err = io.ReadAll(stdin); err != nil {
+			return err
+		}
+		program, err = parser.ParseString(string(src), parser.WithComments())
+	case 1:
+		program, err = parser.ParseFile(flags.Arg(0), parser.WithComments())
+	default:
+		flags.Usage()
+		return errors.New("ast: expected at most one file")
+	}
+	if err != nil {
+		return err
+	}
+	return fprint(stdout, program)
+}
+
+// fprintJSON writes node to w as indented JSON.
+func fprintJSON(w io.Writer, node ast.Node) error {
+	data, err := ast.MarshalJSON(node)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	_, err = w.Write(out.Bytes())
+	return err
+}
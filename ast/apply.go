@@ -0,0 +1,293 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ApplyFunc is called by Apply with a Cursor at each node, it returns
+// whether Apply should go on, see Apply.
+type ApplyFunc func(*Cursor) bool
+
+// Apply walks the tree at root in the order of Walk and returns it with the
+// changes made through the Cursor, which may have replaced root itself.
+//
+// pre is called for each node before its children, and post after them,
+// either may be nil. They are called for missing children too, with a nil
+// Node, so they can be filled in. If pre returns false the children of the
+// node are skipped and post isn't called for it. If post returns false Apply
+// stops and returns at once.
+//
+// Nodes added by Replace, InsertBefore and InsertAfter aren't walked. The
+// Value of a shorthand *FieldValue is its Name, it isn't walked on its own
+// and is set to the new Name when Name is replaced. Apply changes nothing
+// itself, a tree is only changed through the Cursor.
+func Apply(root Node, pre, post ApplyFunc) Node {
+	top := struct{ Root Node }{root}
+	a := &applier{pre: pre, post: post}
+	a.field(nil, "", &top.Root)
+	return top.Root
+}
+
+// Cursor is a node being walked by Apply, and where it is in the tree: the
+// parent node, the field of the parent holding the node and its index when
+// the field is a list.
+type Cursor struct {
+	parent Node
+	name   string
+	node   Node
+	// field is the field of the parent holding the node.
+	field reflect.Value
+	// index is the index of the node in field when it is a list, or -1.
+	index int
+	// step is how far the walk of a list moves on from index.
+	step int
+}
+
+// Node returns the node at the cursor, nil for a missing child.
+func (c *Cursor) Node() Node { return c.node }
+
+// Parent returns the node the cursor's node is a child of, nil for the root.
+func (c *Cursor) Parent() Node { return c.parent }
+
+// Name returns the name of the field of the parent holding the node,
+// "Statements" for a statement of a program.
+func (c *Cursor) Name() string { return c.name }
+
+// Index returns the index of the node in the list it is in, or -1 if it
+// isn't in a list. InsertBefore moves the node along, changing its index.
+func (c *Cursor) Index() int { return c.index }
+
+// Replace puts n where the node is in its parent. It panics if n can't be
+// held by the field.
+func (c *Cursor) Replace(n Node) {
+	slot := c.field
+	if c.index >= 0 {
+		slot = slot.Index(c.index)
+	}
+	slot.Set(value(n, slot.Type()))
+	c.node = n
+}
+
+// Delete removes the node from the list it is in. It panics if the node
+// isn't in a list.
+func (c *Cursor) Delete() {
+	c.mustBeInList("Delete")
+	l := c.field.Len()
+	reflect.Copy(c.field.Slice(c.index, l), c.field.Slice(c.index+1, l))
+	c.field.Index(l - 1).Set(reflect.Zero(c.field.Type().Elem()))
+	c.field.SetLen(l - 1)
+	c.step--
+}
+
+// InsertBefore adds n to the list the node is in, before the node. It
+// panics if the node isn't in a list.
+func (c *Cursor) InsertBefore(n Node) {
+	c.mustBeInList("InsertBefore")
+	c.insert(c.index, n)
+	c.index++
+}
+
+// InsertAfter adds n to the list the node is in, after the node. It panics
+// if the node isn't in a list.
+func (c *Cursor) InsertAfter(n Node) {
+	c.mustBeInList("InsertAfter")
+	c.insert(c.index+1, n)
+	c.step++
+}
+
+func (c *Cursor) mustBeInList(method string) {
+	if c.index < 0 {
+		panic(fmt.Sprintf("ast.Cursor.%s: %s is not a list", method, c.name))
+	}
+}
+
+// insert adds n to the list at i, moving the nodes from i along.
+func (c *Cursor) insert(i int, n Node) {
+	v := value(n, c.field.Type().Elem())
+	l := c.field.Len()
+	c.field.Set(reflect.Append(c.field, reflect.Zero(v.Type())))
+	reflect.Copy(c.field.Slice(i+1, l+1), c.field.Slice(i, l))
+	c.field.Index(i).Set(v)
+}
+
+// value returns n as a value of type t, the type of a field or list
+// element.
+func value(n Node, t reflect.Type) reflect.Value {
+	if n == nil {
+		return reflect.Zero(t)
+	}
+	v := reflect.ValueOf(n)
+	if !v.Type().AssignableTo(t) {
+		panic(fmt.Sprintf("ast.Cursor: cannot use %T as %s", n, t))
+	}
+	return v
+}
+
+type applier struct {
+	pre, post ApplyFunc
+	stopped   bool
+}
+
+// field walks the node held by the field of parent that ptr points to.
+func (a *applier) field(parent Node, name string, ptr interface{}) {
+	field := reflect.ValueOf(ptr).Elem()
+	a.visit(&Cursor{parent: parent, name: name, node: node(field), field: field, index: -1})
+}
+
+// list walks the nodes in the list field of parent that ptr points to. The
+// list is read again after each node, as the cursor may have changed it.
+func (a *applier) list(parent Node, name string, ptr interface{}) {
+	field := reflect.ValueOf(ptr).Elem()
+	c := &Cursor{parent: parent, name: name, field: field}
+	for c.index = 0; c.index < field.Len() && !a.stopped; c.index += c.step {
+		c.node, c.step = node(field.Index(c.index)), 1
+		a.visit(c)
+	}
+}
+
+// node returns the node held by v, nil if it is a nil pointer.
+func node(v reflect.Value) Node {
+	if v.IsNil() {
+		return nil
+	}
+	return v.Interface().(Node)
+}
+
+func (a *applier) visit(c *Cursor) {
+	if a.stopped {
+		return
+	}
+	if a.pre != nil && !a.pre(c) {
+		return
+	}
+	a.children(c.node)
+	if !a.stopped && a.post != nil && !a.post(c) {
+		a.stopped = true
+	}
+}
+
+// children walks the children of n, in the order Walk visits them.
+func (a *applier) children(n Node) {
+	switch n := n.(type) {
+	case nil, *Comment, *Identifier, *IntLiteral, *Boolean, *StringLiteral,
+		*WildcardPattern, *NamedType:
+		// No children.
+	case *Package:
+		a.list(n, "Files", &n.Files)
+	case *Program:
+		a.list(n, "Statements", &n.Statements)
+		a.list(n, "Comments", &n.Comments)
+	case *CommentGroup:
+		a.list(n, "List", &n.List)
+
+	// Statements
+	case *ImportStatement:
+		a.field(n, "Name", &n.Name)
+		a.field(n, "Path", &n.Path)
+	case *AssignmentStatement:
+		a.field(n, "Name", &n.Name)
+		a.field(n, "Target", &n.Target)
+		a.field(n, "Type", &n.Type)
+		a.field(n, "Value", &n.Value)
+	case *PatternAssignmentStatement:
+		a.list(n, "Targets", &n.Targets)
+		a.list(n, "Values", &n.Values)
+	case *ExpressionStatement:
+		a.field(n, "Expression", &n.Expression)
+	case *BlockStatement:
+		a.list(n, "Statements", &n.Statements)
+	case *ForStatement:
+		a.field(n, "Variable", &n.Variable)
+		a.field(n, "Iterable", &n.Iterable)
+		a.field(n, "Body", &n.Body)
+	case *TypeDeclaration:
+		a.field(n, "Name", &n.Name)
+		a.list(n, "Fields", &n.Fields)
+	case *Field:
+		a.field(n, "Name", &n.Name)
+		a.field(n, "Type", &n.Type)
+
+	// Expressions
+	case *InfixExpression:
+		a.field(n, "Left", &n.Left)
+		a.field(n, "Right", &n.Right)
+	case *PrefixExpression:
+		a.field(n, "Right", &n.Right)
+	case *ArrayLiteral:
+		a.list(n, "Elements", &n.Elements)
+	case *MapLiteral:
+		a.list(n, "Pairs", &n.Pairs)
+	case *MapPair:
+		a.field(n, "Key", &n.Key)
+		a.field(n, "Value", &n.Value)
+	case *IndexExpression:
+		a.field(n, "Left", &n.Left)
+		a.field(n, "Index", &n.Index)
+	case *FunctionLiteral:
+		a.list(n, "Parameters", &n.Parameters)
+		a.field(n, "ReturnType", &n.ReturnType)
+		a.field(n, "Body", &n.Body)
+	case *Parameter:
+		a.field(n, "Name", &n.Name)
+		a.field(n, "Type", &n.Type)
+	case *RecordLiteral:
+		a.field(n, "Type", &n.Type)
+		a.list(n, "Fields", &n.Fields)
+	case *FieldValue:
+		name := n.Name
+		a.field(n, "Name", &n.Name)
+		if !n.Shorthand {
+			a.field(n, "Value", &n.Value)
+		} else if n.Name != name {
+			n.Value = n.Name
+		}
+	case *SelectorExpression:
+		a.field(n, "X", &n.X)
+		a.field(n, "Sel", &n.Sel)
+	case *CallExpression:
+		a.field(n, "Function", &n.Function)
+		a.list(n, "Arguments", &n.Arguments)
+	case *PipeExpression:
+		a.field(n, "Left", &n.Left)
+		a.field(n, "Right", &n.Right)
+	case *RangeExpression:
+		a.field(n, "Low", &n.Low)
+		a.field(n, "High", &n.High)
+	case *MatchExpression:
+		a.field(n, "Subject", &n.Subject)
+		a.list(n, "Arms", &n.Arms)
+	case *MatchArm:
+		a.field(n, "Pattern", &n.Pattern)
+		a.field(n, "Guard", &n.Guard)
+		a.field(n, "Body", &n.Body)
+
+	// Patterns
+	case *BindingPattern:
+		a.field(n, "Name", &n.Name)
+	case *LiteralPattern:
+		a.field(n, "Value", &n.Value)
+	case *ArrayPattern:
+		a.list(n, "Elements", &n.Elements)
+	case *MapPattern:
+		a.list(n, "Pairs", &n.Pairs)
+	case *MapPatternPair:
+		a.field(n, "Key", &n.Key)
+		a.field(n, "Value", &n.Value)
+
+	// Types
+	case *ListType:
+		a.field(n, "Elem", &n.Elem)
+	case *MapType:
+		a.field(n, "Key", &n.Key)
+		a.field(n, "Value", &n.Value)
+	case *FunctionType:
+		a.list(n, "Params", &n.Params)
+		a.field(n, "Result", &n.Result)
+	case *OptionalType:
+		a.field(n, "Elem", &n.Elem)
+
+	default:
+		panic(fmt.Sprintf("ast.Apply: unexpected node type %T", n))
+	}
+}
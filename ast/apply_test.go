@@ -0,0 +1,188 @@
+package ast_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/joerdav/brev/ast"
+	brevparser "github.com/joerdav/brev/parser"
+	"github.com/joerdav/brev/printer"
+	"github.com/matryer/is"
+)
+
+func format(t *testing.T, n ast.Node) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, n); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestApply(t *testing.T) {
+	src := `n = size(xs)
+debug(n)
+g = f() {
+	debug(size(ys))
+	size
+}
+`
+	isDebug := func(n ast.Node) bool {
+		es, ok := n.(*ast.ExpressionStatement)
+		if !ok {
+			return false
+		}
+		call, ok := es.Expression.(*ast.CallExpression)
+		return ok && call.Function.String() == "debug"
+	}
+	tests := []struct {
+		name     string
+		pre      ast.ApplyFunc
+		expected string
+	}{
+		{
+			name: "replace",
+			pre: func(c *ast.Cursor) bool {
+				if id, ok := c.Node().(*ast.Identifier); ok && id.Value == "size" {
+					c.Replace(&ast.Identifier{Token: id.Token, Value: "len"})
+				}
+				return true
+			},
+			expected: "n = len(xs)\ndebug(n)\ng = f() {\n\tdebug(len(ys))\n\tlen\n}\n",
+		},
+		{
+			name: "delete",
+			pre: func(c *ast.Cursor) bool {
+				if isDebug(c.Node()) {
+					c.Delete()
+				}
+				return true
+			},
+			// The blank line is where debug(n) was in the source.
+			expected: "n = size(xs)\n\ng = f() {\n\tsize\n}\n",
+		},
+		{
+			name: "insert",
+			pre: func(c *ast.Cursor) bool {
+				if isDebug(c.Node()) {
+					c.InsertBefore(&ast.ExpressionStatement{Expression: &ast.Identifier{Value: "before"}})
+					c.InsertAfter(&ast.ExpressionStatement{Expression: &ast.Identifier{Value: "after"}})
+				}
+				return true
+			},
+			expected: "n = size(xs)\nbefore\ndebug(n)\nafter\ng = f() {\n\tbefore\n\tdebug(size(ys))\n\tafter\n\tsize\n}\n",
+		},
+		{
+			name: "skip children",
+			pre: func(c *ast.Cursor) bool {
+				if _, ok := c.Node().(*ast.FunctionLiteral); ok {
+					return false
+				}
+				if id, ok := c.Node().(*ast.Identifier); ok && id.Value == "size" {
+					c.Replace(&ast.Identifier{Value: "len"})
+				}
+				return true
+			},
+			expected: "n = len(xs)\ndebug(n)\ng = f() {\n\tdebug(size(ys))\n\tsize\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			program, err := brevparser.ParseString(src)
+			is.NoErr(err)
+			result := ast.Apply(program, tt.pre, nil)
+			is.Equal(result, program)
+			is.Equal(format(t, result), tt.expected)
+		})
+	}
+}
+
+func TestApplyReplaceShorthandName(t *testing.T) {
+	is := is.New(t)
+	program, err := brevparser.ParseString("P{x}")
+	is.NoErr(err)
+	ast.Apply(program, func(c *ast.Cursor) bool {
+		if id, ok := c.Node().(*ast.Identifier); ok && id.Value == "x" {
+			c.Replace(&ast.Identifier{Token: id.Token, Value: "y"})
+		}
+		return true
+	}, nil)
+	record := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.RecordLiteral)
+	is.True(record.Fields[0].Value == record.Fields[0].Name)
+	is.Equal(format(t, program), "P{y}\n")
+}
+
+func TestApplyReplaceRoot(t *testing.T) {
+	is := is.New(t)
+	program, err := brevparser.ParseExpr("a + b")
+	is.NoErr(err)
+	root := program.Statements[0].(*ast.ExpressionStatement).Expression
+	result := ast.Apply(root, nil, func(c *ast.Cursor) bool {
+		if ie, ok := c.Node().(*ast.InfixExpression); ok {
+			c.Replace(&ast.CallExpression{Function: &ast.Identifier{Value: "add"}, Arguments: []ast.Expression{ie.Left, ie.Right}})
+		}
+		return true
+	})
+	is.Equal(format(t, result), "add(a, b)")
+}
+
+func TestApplyAbort(t *testing.T) {
+	is := is.New(t)
+	program, err := brevparser.ParseString("a b c")
+	is.NoErr(err)
+	var seen []string
+	ast.Apply(program, nil, func(c *ast.Cursor) bool {
+		if id, ok := c.Node().(*ast.Identifier); ok {
+			seen = append(seen, id.Value)
+			return id.Value != "b"
+		}
+		return true
+	})
+	is.Equal(seen, []string{"a", "b"})
+}
+
+func TestApplyCursor(t *testing.T) {
+	is := is.New(t)
+	program, err := brevparser.ParseString("a b")
+	is.NoErr(err)
+	ast.Apply(program, func(c *ast.Cursor) bool {
+		if _, ok := c.Node().(*ast.ExpressionStatement); ok {
+			is.Equal(c.Parent(), program)
+			is.Equal(c.Name(), "Statements")
+			is.True(c.Index() >= 0)
+		}
+		if _, ok := c.Node().(*ast.Identifier); ok {
+			is.Equal(c.Name(), "Expression")
+			is.Equal(c.Index(), -1)
+		}
+		return true
+	}, nil)
+}
+
+func TestApplyPanics(t *testing.T) {
+	tests := []struct {
+		name     string
+		edit     func(*ast.Cursor)
+		expected string
+	}{
+		{"delete outside slice", (*ast.Cursor).Delete, "ast.Cursor.Delete: Expression is not a list"},
+		{"wrong type", func(c *ast.Cursor) { c.Replace(&ast.NamedType{Name: "int"}) }, "ast.Cursor: cannot use *ast.NamedType as ast.Expression"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			program, err := brevparser.ParseString("a")
+			is.NoErr(err)
+			defer func() {
+				is.Equal(recover(), tt.expected)
+			}()
+			ast.Apply(program, func(c *ast.Cursor) bool {
+				if _, ok := c.Node().(*ast.Identifier); ok {
+					tt.edit(c)
+				}
+				return true
+			}, nil)
+		})
+	}
+}
@@ -3,6 +3,11 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"math/big"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/joerdav/brev/tokens"
 )
@@ -10,6 +15,10 @@ import (
 type Node interface {
 	fmt.Stringer
 	TokenLiteral() string
+	// Pos is the position of the node's first character and End the
+	// position immediately after its last.
+	Pos() tokens.Position
+	End() tokens.Position
 }
 
 type Statement interface {
@@ -22,10 +31,65 @@ type Expression interface {
 	expressionNode()
 }
 
+// TypeExpr is an optional type annotation on a parameter, function result or
+// assignment.
+type TypeExpr interface {
+	Node
+	typeNode()
+}
+
+// Pattern is the left hand side of a match arm, it is tested against a value
+// and may bind names.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
 var _ Node = (*Program)(nil)
 
 type Program struct {
+	// Filename is the file the program was parsed from, if any.
+	Filename string
+	// Doc is the file's doc comment, a comment group at the top of the
+	// file separated from the first statement by a blank line.
+	Doc        *CommentGroup
 	Statements []Statement
+	// Comments holds every comment group in the source, in order, when
+	// parsed with comments enabled.
+	Comments []*CommentGroup
+	// CommentMap attaches comment groups in Comments to the nodes they
+	// describe.
+	CommentMap CommentMap
+}
+
+// Unattached returns the comment groups that are neither the doc comment
+// nor attached to a node.
+func (p *Program) Unattached() []*CommentGroup {
+	attached := map[*CommentGroup]bool{p.Doc: true}
+	for _, c := range p.CommentMap {
+		attached[c.Leading] = true
+		attached[c.Trailing] = true
+	}
+	var unattached []*CommentGroup
+	for _, g := range p.Comments {
+		if !attached[g] {
+			unattached = append(unattached, g)
+		}
+	}
+	return unattached
+}
+
+// Imports returns the import statements at the top of the program.
+func (p *Program) Imports() []*ImportStatement {
+	var imports []*ImportStatement
+	for _, s := range p.Statements {
+		is, ok := s.(*ImportStatement)
+		if !ok {
+			break
+		}
+		imports = append(imports, is)
+	}
+	return imports
 }
 
 func (p *Program) TokenLiteral() string {
@@ -35,6 +99,20 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() tokens.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return tokens.Position{File: p.Filename}
+}
+
+func (p *Program) End() tokens.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return tokens.Position{File: p.Filename}
+}
+
 func (p *Program) String() string {
 	var buf bytes.Buffer
 	for _, s := range p.Statements {
@@ -43,26 +121,267 @@ func (p *Program) String() string {
 	return buf.String()
 }
 
-var _ Statement = (*AssignmentStatement)(nil)
+var _ Node = (*Comment)(nil)
 
-type AssignmentStatement struct {
+// Comment is a single `//` line comment.
+type Comment struct {
+	Token tokens.Token
+}
+
+// Text returns the comment without the leading slashes.
+func (c *Comment) Text() string {
+	return strings.TrimPrefix(c.Token.Literal, "//")
+}
+
+func (c *Comment) TokenLiteral() string { return c.Token.Literal }
+func (c *Comment) Pos() tokens.Position { return c.Token.Pos() }
+func (c *Comment) End() tokens.Position { return c.Token.End() }
+func (c *Comment) String() string       { return c.Token.Literal }
+
+var _ Node = (*Package)(nil)
+
+// Package is a set of programs parsed together, one per file.
+type Package struct {
+	Files []*Program
+}
+
+func (p *Package) TokenLiteral() string {
+	if len(p.Files) > 0 {
+		return p.Files[0].TokenLiteral()
+	}
+	return ""
+}
+
+func (p *Package) Pos() tokens.Position {
+	if len(p.Files) > 0 {
+		return p.Files[0].Pos()
+	}
+	return tokens.Position{}
+}
+
+func (p *Package) End() tokens.Position {
+	if len(p.Files) > 0 {
+		return p.Files[len(p.Files)-1].End()
+	}
+	return tokens.Position{}
+}
+
+func (p *Package) String() string {
+	var buf bytes.Buffer
+	for _, f := range p.Files {
+		buf.WriteString(f.String())
+	}
+	return buf.String()
+}
+
+var _ Statement = (*ImportStatement)(nil)
+
+// ImportStatement makes another file's definitions available, under Name if
+// given or else the last element of Path.
+//
+//	im "path/to/lib"
+//	im util "path/to/lib"
+type ImportStatement struct {
 	Token tokens.Token
 	Name  *Identifier
-	Value Expression
+	Path  *StringLiteral
+}
+
+// LocalName is the name the import is bound to, the alias if given or else
+// the last element of the path without its extension.
+func (is *ImportStatement) LocalName() string {
+	if is.Name != nil {
+		return is.Name.Value
+	}
+	base := path.Base(is.Path.Value)
+	return strings.TrimSuffix(base, path.Ext(base))
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) Pos() tokens.Position { return is.Token.Pos() }
+func (is *ImportStatement) End() tokens.Position { return endOr(is.Path, is.Token) }
+func (is *ImportStatement) String() string {
+	if is.Name != nil {
+		return "im " + is.Name.String() + " " + is.Path.String()
+	}
+	return "im " + is.Path.String()
+}
+
+var _ Statement = (*AssignmentStatement)(nil)
+
+// AssignmentStatement assigns Value to a plain identifier, Name, or to an
+// index or selector expression, Target. Exactly one of the two is set.
+//
+// Operator is the arithmetic operator of a compound assignment, "+" for
+// `count += 1`, and is empty for plain assignment.
+//
+// Type is the optional annotation in `x: str = "hi"`, only plain assignment
+// to a Name may be annotated.
+type AssignmentStatement struct {
+	Token    tokens.Token
+	Name     *Identifier
+	Target   Expression
+	Operator string
+	Type     TypeExpr
+	Value    Expression
+}
+
+// Left returns the expression being assigned to.
+func (as *AssignmentStatement) Left() Expression {
+	if as.Name != nil {
+		return as.Name
+	}
+	return as.Target
 }
 
 func (as *AssignmentStatement) statementNode()       {}
 func (as *AssignmentStatement) TokenLiteral() string { return as.Token.Literal }
+func (as *AssignmentStatement) Pos() tokens.Position { return posOr(as.Left(), as.Token) }
+func (as *AssignmentStatement) End() tokens.Position { return endOr(as.Value, as.Token) }
 func (as *AssignmentStatement) String() string {
 	var buf bytes.Buffer
-	buf.WriteString(as.TokenLiteral())
-	buf.WriteString(" = ")
+	if left := as.Left(); left != nil {
+		buf.WriteString(left.String())
+	}
+	if as.Type != nil {
+		buf.WriteString(": " + as.Type.String())
+	}
+	buf.WriteString(" " + as.Operator + "= ")
 	if as.Value != nil {
 		buf.WriteString(as.Value.String())
 	}
 	return buf.String()
 }
 
+var _ Statement = (*PatternAssignmentStatement)(nil)
+
+// PatternAssignmentStatement assigns each of Values to the pattern at the
+// same index in Targets.
+//
+//	a, b = b, a
+//	[x, y] = pair
+//	{name, age} = person
+type PatternAssignmentStatement struct {
+	Token   tokens.Token
+	Targets []Pattern
+	Values  []Expression
+}
+
+func (pas *PatternAssignmentStatement) statementNode()       {}
+func (pas *PatternAssignmentStatement) TokenLiteral() string { return pas.Token.Literal }
+func (pas *PatternAssignmentStatement) Pos() tokens.Position {
+	if len(pas.Targets) == 0 {
+		return pas.Token.Pos()
+	}
+	return posOr(pas.Targets[0], pas.Token)
+}
+func (pas *PatternAssignmentStatement) End() tokens.Position {
+	if len(pas.Values) == 0 {
+		return pas.Token.End()
+	}
+	return endOr(pas.Values[len(pas.Values)-1], pas.Token)
+}
+func (pas *PatternAssignmentStatement) String() string {
+	targets := make([]string, len(pas.Targets))
+	for i, t := range pas.Targets {
+		targets[i] = t.String()
+	}
+	values := make([]string, len(pas.Values))
+	for i, v := range pas.Values {
+		values[i] = v.String()
+	}
+	return strings.Join(targets, ", ") + " = " + strings.Join(values, ", ")
+}
+
+var _ Statement = (*BlockStatement)(nil)
+
+type BlockStatement struct {
+	Token      tokens.Token
+	Statements []Statement
+	Rbrace     tokens.Token
+}
+
+func (bs *BlockStatement) statementNode()       {}
+func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() tokens.Position { return bs.Token.Pos() }
+func (bs *BlockStatement) End() tokens.Position { return bs.Rbrace.End() }
+func (bs *BlockStatement) String() string {
+	stmts := make([]string, len(bs.Statements))
+	for i, s := range bs.Statements {
+		stmts[i] = s.String()
+	}
+	return "{ " + strings.Join(stmts, "; ") + " }"
+}
+
+var _ Statement = (*ForStatement)(nil)
+
+// ForStatement runs Body once for each item of Iterable with the item bound
+// to Variable.
+//
+//	l x in 1..=10 { total += x }
+type ForStatement struct {
+	Token    tokens.Token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Pos() tokens.Position { return fs.Token.Pos() }
+func (fs *ForStatement) End() tokens.Position { return endOr(fs.Body, fs.Token) }
+func (fs *ForStatement) String() string {
+	return "l " + fs.Variable.String() + " in " + fs.Iterable.String() + " " + fs.Body.String()
+}
+
+var _ Statement = (*TypeDeclaration)(nil)
+
+// TypeDeclaration declares a record type with named fields.
+//
+//	t Point { x: int, y: int }
+type TypeDeclaration struct {
+	Token  tokens.Token
+	Name   *Identifier
+	Fields []*Field
+	Rbrace tokens.Token
+}
+
+func (td *TypeDeclaration) statementNode()       {}
+func (td *TypeDeclaration) TokenLiteral() string { return td.Token.Literal }
+func (td *TypeDeclaration) Pos() tokens.Position { return td.Token.Pos() }
+func (td *TypeDeclaration) End() tokens.Position { return td.Rbrace.End() }
+func (td *TypeDeclaration) String() string {
+	fields := make([]string, len(td.Fields))
+	for i, f := range td.Fields {
+		fields[i] = f.String()
+	}
+	return "t " + td.Name.String() + " { " + strings.Join(fields, ", ") + " }"
+}
+
+var _ Node = (*Field)(nil)
+
+// Field is a record field declaration with an optional type, `x` or `x: int`.
+type Field struct {
+	Name *Identifier
+	Type TypeExpr
+}
+
+func (f *Field) TokenLiteral() string { return f.Name.TokenLiteral() }
+func (f *Field) Pos() tokens.Position { return f.Name.Pos() }
+func (f *Field) End() tokens.Position {
+	if f.Type != nil {
+		return f.Type.End()
+	}
+	return f.Name.End()
+}
+func (f *Field) String() string {
+	if f.Type != nil {
+		return f.Name.String() + ": " + f.Type.String()
+	}
+	return f.Name.String()
+}
+
 var _ Expression = (*Identifier)(nil)
 
 type Identifier struct {
@@ -72,6 +391,8 @@ type Identifier struct {
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() tokens.Position { return i.Token.Pos() }
+func (i *Identifier) End() tokens.Position { return i.Token.End() }
 func (i *Identifier) String() string       { return i.Value }
 
 var _ Statement = (*ExpressionStatement)(nil)
@@ -83,6 +404,8 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() tokens.Position { return posOr(es.Expression, es.Token) }
+func (es *ExpressionStatement) End() tokens.Position { return endOr(es.Expression, es.Token) }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -100,6 +423,8 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() tokens.Position { return posOr(ie.Left, ie.Token) }
+func (ie *InfixExpression) End() tokens.Position { return endOr(ie.Right, ie.Token) }
 func (ie *InfixExpression) String() string {
 	var buf bytes.Buffer
 	buf.WriteString(ie.Left.String())
@@ -118,6 +443,8 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() tokens.Position { return pe.Token.Pos() }
+func (pe *PrefixExpression) End() tokens.Position { return endOr(pe.Right, pe.Token) }
 func (pe *PrefixExpression) String() string {
 	var buf bytes.Buffer
 	buf.WriteString(pe.Operator)
@@ -127,13 +454,607 @@ func (pe *PrefixExpression) String() string {
 
 var _ Expression = (*IntLiteral)(nil)
 
+// IntLiteral is an integer, Big holds its value instead of Value when it
+// doesn't fit in an int64.
 type IntLiteral struct {
 	Token tokens.Token
 	Value int64
+	Big   *big.Int
 }
 
 func (il *IntLiteral) expressionNode()      {}
 func (il *IntLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntLiteral) Pos() tokens.Position { return il.Token.Pos() }
+func (il *IntLiteral) End() tokens.Position { return il.Token.End() }
 func (il *IntLiteral) String() string {
+	if il.Big != nil {
+		return il.Big.String()
+	}
 	return fmt.Sprint(il.Value)
 }
+
+var _ Expression = (*Boolean)(nil)
+
+type Boolean struct {
+	Token tokens.Token
+	Value bool
+}
+
+func (b *Boolean) expressionNode()      {}
+func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() tokens.Position { return b.Token.Pos() }
+func (b *Boolean) End() tokens.Position { return b.Token.End() }
+func (b *Boolean) String() string       { return b.Token.Literal }
+
+var _ Expression = (*StringLiteral)(nil)
+
+type StringLiteral struct {
+	Token tokens.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() tokens.Position { return sl.Token.Pos() }
+func (sl *StringLiteral) End() tokens.Position { return sl.Token.End() }
+func (sl *StringLiteral) String() string       { return strconv.Quote(sl.Value) }
+
+var _ Expression = (*ArrayLiteral)(nil)
+
+type ArrayLiteral struct {
+	Token    tokens.Token
+	Elements []Expression
+	Rbrack   tokens.Token
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() tokens.Position { return al.Token.Pos() }
+func (al *ArrayLiteral) End() tokens.Position { return al.Rbrack.End() }
+func (al *ArrayLiteral) String() string {
+	elems := make([]string, len(al.Elements))
+	for i, e := range al.Elements {
+		elems[i] = e.String()
+	}
+	return "[" + strings.Join(elems, ", ") + "]"
+}
+
+var _ Expression = (*MapLiteral)(nil)
+
+type MapLiteral struct {
+	Token  tokens.Token
+	Pairs  []*MapPair
+	Rbrace tokens.Token
+}
+
+func (ml *MapLiteral) expressionNode()      {}
+func (ml *MapLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MapLiteral) Pos() tokens.Position { return ml.Token.Pos() }
+func (ml *MapLiteral) End() tokens.Position { return ml.Rbrace.End() }
+func (ml *MapLiteral) String() string {
+	pairs := make([]string, len(ml.Pairs))
+	for i, p := range ml.Pairs {
+		pairs[i] = p.String()
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+var _ Node = (*MapPair)(nil)
+
+// MapPair is a single entry of a MapLiteral. In the shorthand form `{name}`
+// Key is the string "name" and Value is the identifier name.
+type MapPair struct {
+	Token     tokens.Token
+	Key       Expression
+	Value     Expression
+	Shorthand bool
+}
+
+func (mp *MapPair) TokenLiteral() string { return mp.Token.Literal }
+func (mp *MapPair) Pos() tokens.Position {
+	if mp.Shorthand {
+		return posOr(mp.Value, mp.Token)
+	}
+	return posOr(mp.Key, mp.Token)
+}
+func (mp *MapPair) End() tokens.Position { return endOr(mp.Value, mp.Token) }
+func (mp *MapPair) String() string {
+	if mp.Shorthand {
+		return mp.Value.String()
+	}
+	return mp.Key.String() + ": " + mp.Value.String()
+}
+
+var _ Expression = (*IndexExpression)(nil)
+
+type IndexExpression struct {
+	Token  tokens.Token
+	Left   Expression
+	Index  Expression
+	Rbrack tokens.Token
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() tokens.Position { return posOr(ie.Left, ie.Token) }
+func (ie *IndexExpression) End() tokens.Position { return ie.Rbrack.End() }
+func (ie *IndexExpression) String() string {
+	return ie.Left.String() + "[" + ie.Index.String() + "]"
+}
+
+var _ Expression = (*FunctionLiteral)(nil)
+
+type FunctionLiteral struct {
+	Token      tokens.Token
+	Parameters []*Parameter
+	ReturnType TypeExpr
+	Body       *BlockStatement
+}
+
+func (fl *FunctionLiteral) expressionNode()      {}
+func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() tokens.Position { return fl.Token.Pos() }
+func (fl *FunctionLiteral) End() tokens.Position { return endOr(fl.Body, fl.Token) }
+func (fl *FunctionLiteral) String() string {
+	var buf bytes.Buffer
+	params := make([]string, len(fl.Parameters))
+	for i, p := range fl.Parameters {
+		params[i] = p.String()
+	}
+	buf.WriteString("f(" + strings.Join(params, ", ") + ") ")
+	if fl.ReturnType != nil {
+		buf.WriteString("-> " + fl.ReturnType.String() + " ")
+	}
+	buf.WriteString(fl.Body.String())
+	return buf.String()
+}
+
+var _ Node = (*Parameter)(nil)
+
+// Parameter is a function parameter with an optional type, `a` or `a: int`.
+type Parameter struct {
+	Name *Identifier
+	Type TypeExpr
+}
+
+func (p *Parameter) TokenLiteral() string { return p.Name.TokenLiteral() }
+func (p *Parameter) Pos() tokens.Position { return p.Name.Pos() }
+func (p *Parameter) End() tokens.Position {
+	if p.Type != nil {
+		return p.Type.End()
+	}
+	return p.Name.End()
+}
+func (p *Parameter) String() string {
+	if p.Type != nil {
+		return p.Name.String() + ": " + p.Type.String()
+	}
+	return p.Name.String()
+}
+
+var _ Expression = (*RecordLiteral)(nil)
+
+// RecordLiteral constructs a value of a declared record type,
+// `Point{x: 1, y: 2}`.
+type RecordLiteral struct {
+	Token  tokens.Token
+	Type   Expression
+	Fields []*FieldValue
+	Rbrace tokens.Token
+}
+
+func (rl *RecordLiteral) expressionNode()      {}
+func (rl *RecordLiteral) TokenLiteral() string { return rl.Token.Literal }
+func (rl *RecordLiteral) Pos() tokens.Position { return posOr(rl.Type, rl.Token) }
+func (rl *RecordLiteral) End() tokens.Position { return rl.Rbrace.End() }
+func (rl *RecordLiteral) String() string {
+	fields := make([]string, len(rl.Fields))
+	for i, f := range rl.Fields {
+		fields[i] = f.String()
+	}
+	return rl.Type.String() + "{" + strings.Join(fields, ", ") + "}"
+}
+
+var _ Node = (*FieldValue)(nil)
+
+// FieldValue sets a single field of a RecordLiteral. In the shorthand form
+// `Point{x}` Value is the identifier x.
+type FieldValue struct {
+	Name      *Identifier
+	Value     Expression
+	Shorthand bool
+}
+
+func (fv *FieldValue) TokenLiteral() string { return fv.Name.TokenLiteral() }
+func (fv *FieldValue) Pos() tokens.Position { return fv.Name.Pos() }
+func (fv *FieldValue) End() tokens.Position {
+	if fv.Shorthand || isNil(fv.Value) {
+		return fv.Name.End()
+	}
+	return fv.Value.End()
+}
+func (fv *FieldValue) String() string {
+	if fv.Shorthand {
+		return fv.Name.String()
+	}
+	return fv.Name.String() + ": " + fv.Value.String()
+}
+
+var _ Expression = (*SelectorExpression)(nil)
+
+// SelectorExpression selects the field Sel of X, `p.x`.
+type SelectorExpression struct {
+	Token tokens.Token
+	X     Expression
+	Sel   *Identifier
+}
+
+func (se *SelectorExpression) expressionNode()      {}
+func (se *SelectorExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SelectorExpression) Pos() tokens.Position { return posOr(se.X, se.Token) }
+func (se *SelectorExpression) End() tokens.Position { return endOr(se.Sel, se.Token) }
+func (se *SelectorExpression) String() string {
+	return se.X.String() + "." + se.Sel.String()
+}
+
+var _ Expression = (*CallExpression)(nil)
+
+type CallExpression struct {
+	Token     tokens.Token
+	Function  Expression
+	Arguments []Expression
+	Rparen    tokens.Token
+}
+
+func (ce *CallExpression) expressionNode()      {}
+func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() tokens.Position { return posOr(ce.Function, ce.Token) }
+func (ce *CallExpression) End() tokens.Position { return ce.Rparen.End() }
+func (ce *CallExpression) String() string {
+	args := make([]string, len(ce.Arguments))
+	for i, a := range ce.Arguments {
+		args[i] = a.String()
+	}
+	return ce.Function.String() + "(" + strings.Join(args, ", ") + ")"
+}
+
+var _ Expression = (*PipeExpression)(nil)
+
+// PipeExpression passes Left as the first argument to Right, `x |> f(y)` is
+// `f(x, y)`. Any other Right is called with Left, `x |> fs[0]` is
+// `fs[0](x)`.
+type PipeExpression struct {
+	Token tokens.Token
+	Left  Expression
+	Right Expression
+}
+
+func (pe *PipeExpression) expressionNode()      {}
+func (pe *PipeExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PipeExpression) Pos() tokens.Position { return posOr(pe.Left, pe.Token) }
+func (pe *PipeExpression) End() tokens.Position { return endOr(pe.Right, pe.Token) }
+func (pe *PipeExpression) String() string {
+	return pe.Left.String() + " |> " + pe.Right.String()
+}
+
+var _ Expression = (*RangeExpression)(nil)
+
+// RangeExpression is the half open range `Low..High`, or the closed range
+// `Low..=High` when Inclusive. Either bound may be omitted when slicing,
+// `xs[..3]`.
+type RangeExpression struct {
+	Token     tokens.Token
+	Low, High Expression
+	Inclusive bool
+}
+
+func (re *RangeExpression) expressionNode()      {}
+func (re *RangeExpression) TokenLiteral() string { return re.Token.Literal }
+func (re *RangeExpression) Pos() tokens.Position {
+	if re.Low != nil {
+		return re.Low.Pos()
+	}
+	return re.Token.Pos()
+}
+
+func (re *RangeExpression) End() tokens.Position {
+	if re.High != nil {
+		return re.High.End()
+	}
+	return re.Token.End()
+}
+
+func (re *RangeExpression) String() string {
+	var buf bytes.Buffer
+	if re.Low != nil {
+		buf.WriteString(re.Low.String())
+	}
+	buf.WriteString(re.Token.Literal)
+	if re.High != nil {
+		buf.WriteString(re.High.String())
+	}
+	return buf.String()
+}
+
+var _ Expression = (*MatchExpression)(nil)
+
+// MatchExpression evaluates the body of the first arm whose pattern, and
+// guard if present, match the subject.
+//
+//	m value { 0 -> "zero", [x, y] -> x + y, _ -> "other" }
+type MatchExpression struct {
+	Token   tokens.Token
+	Subject Expression
+	Arms    []*MatchArm
+	Rbrace  tokens.Token
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) Pos() tokens.Position { return me.Token.Pos() }
+func (me *MatchExpression) End() tokens.Position { return me.Rbrace.End() }
+func (me *MatchExpression) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("m ")
+	buf.WriteString(me.Subject.String())
+	buf.WriteString(" { ")
+	arms := make([]string, len(me.Arms))
+	for i, a := range me.Arms {
+		arms[i] = a.String()
+	}
+	buf.WriteString(strings.Join(arms, ", "))
+	buf.WriteString(" }")
+	return buf.String()
+}
+
+var _ Node = (*MatchArm)(nil)
+
+type MatchArm struct {
+	Token   tokens.Token
+	Pattern Pattern
+	Guard   Expression
+	Body    Expression
+}
+
+func (ma *MatchArm) TokenLiteral() string { return ma.Token.Literal }
+func (ma *MatchArm) Pos() tokens.Position { return posOr(ma.Pattern, ma.Token) }
+func (ma *MatchArm) End() tokens.Position { return endOr(ma.Body, ma.Token) }
+func (ma *MatchArm) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(ma.Pattern.String())
+	if ma.Guard != nil {
+		buf.WriteString(" i ")
+		buf.WriteString(ma.Guard.String())
+	}
+	buf.WriteString(" -> ")
+	buf.WriteString(ma.Body.String())
+	return buf.String()
+}
+
+var _ Pattern = (*WildcardPattern)(nil)
+
+// WildcardPattern `_` matches anything without binding it.
+type WildcardPattern struct {
+	Token tokens.Token
+}
+
+func (wp *WildcardPattern) patternNode()         {}
+func (wp *WildcardPattern) TokenLiteral() string { return wp.Token.Literal }
+func (wp *WildcardPattern) Pos() tokens.Position { return wp.Token.Pos() }
+func (wp *WildcardPattern) End() tokens.Position { return wp.Token.End() }
+func (wp *WildcardPattern) String() string       { return "_" }
+
+var _ Pattern = (*BindingPattern)(nil)
+
+// BindingPattern matches anything and binds it to Name.
+type BindingPattern struct {
+	Token tokens.Token
+	Name  *Identifier
+}
+
+func (bp *BindingPattern) patternNode()         {}
+func (bp *BindingPattern) TokenLiteral() string { return bp.Token.Literal }
+func (bp *BindingPattern) Pos() tokens.Position { return bp.Token.Pos() }
+func (bp *BindingPattern) End() tokens.Position { return bp.Token.End() }
+func (bp *BindingPattern) String() string       { return bp.Name.String() }
+
+var _ Pattern = (*LiteralPattern)(nil)
+
+// LiteralPattern matches values equal to an int, string or boolean literal.
+type LiteralPattern struct {
+	Token tokens.Token
+	Value Expression
+}
+
+func (lp *LiteralPattern) patternNode()         {}
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Token.Literal }
+func (lp *LiteralPattern) Pos() tokens.Position { return posOr(lp.Value, lp.Token) }
+func (lp *LiteralPattern) End() tokens.Position { return endOr(lp.Value, lp.Token) }
+func (lp *LiteralPattern) String() string       { return lp.Value.String() }
+
+var _ Pattern = (*ArrayPattern)(nil)
+
+// ArrayPattern matches arrays of exactly len(Elements) items.
+type ArrayPattern struct {
+	Token    tokens.Token
+	Elements []Pattern
+	Rbrack   tokens.Token
+}
+
+func (ap *ArrayPattern) patternNode()         {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) Pos() tokens.Position { return ap.Token.Pos() }
+func (ap *ArrayPattern) End() tokens.Position { return ap.Rbrack.End() }
+func (ap *ArrayPattern) String() string {
+	elems := make([]string, len(ap.Elements))
+	for i, e := range ap.Elements {
+		elems[i] = e.String()
+	}
+	return "[" + strings.Join(elems, ", ") + "]"
+}
+
+var _ Pattern = (*MapPattern)(nil)
+
+// MapPattern matches maps containing every key in Pairs, extra keys are
+// allowed.
+type MapPattern struct {
+	Token  tokens.Token
+	Pairs  []*MapPatternPair
+	Rbrace tokens.Token
+}
+
+func (mp *MapPattern) patternNode()         {}
+func (mp *MapPattern) TokenLiteral() string { return mp.Token.Literal }
+func (mp *MapPattern) Pos() tokens.Position { return mp.Token.Pos() }
+func (mp *MapPattern) End() tokens.Position { return mp.Rbrace.End() }
+func (mp *MapPattern) String() string {
+	pairs := make([]string, len(mp.Pairs))
+	for i, p := range mp.Pairs {
+		pairs[i] = p.String()
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+var _ Node = (*MapPatternPair)(nil)
+
+// MapPatternPair matches the value under Key against Value. In the shorthand
+// form `{name}` Key is the string "name" and Value binds name.
+type MapPatternPair struct {
+	Token     tokens.Token
+	Key       Expression
+	Value     Pattern
+	Shorthand bool
+}
+
+func (mpp *MapPatternPair) TokenLiteral() string { return mpp.Token.Literal }
+func (mpp *MapPatternPair) Pos() tokens.Position {
+	if mpp.Shorthand {
+		return posOr(mpp.Value, mpp.Token)
+	}
+	return posOr(mpp.Key, mpp.Token)
+}
+func (mpp *MapPatternPair) End() tokens.Position { return endOr(mpp.Value, mpp.Token) }
+func (mpp *MapPatternPair) String() string {
+	if mpp.Shorthand {
+		return mpp.Value.String()
+	}
+	return mpp.Key.String() + ": " + mpp.Value.String()
+}
+
+var _ TypeExpr = (*NamedType)(nil)
+
+// NamedType refers to a type by name, `int` or `Point`.
+type NamedType struct {
+	Token tokens.Token
+	Name  string
+}
+
+func (nt *NamedType) typeNode()            {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) Pos() tokens.Position { return nt.Token.Pos() }
+func (nt *NamedType) End() tokens.Position { return nt.Token.End() }
+func (nt *NamedType) String() string       { return nt.Name }
+
+var _ TypeExpr = (*ListType)(nil)
+
+// ListType is an array of Elem, `[int]`.
+type ListType struct {
+	Token  tokens.Token
+	Elem   TypeExpr
+	Rbrack tokens.Token
+}
+
+func (lt *ListType) typeNode()            {}
+func (lt *ListType) TokenLiteral() string { return lt.Token.Literal }
+func (lt *ListType) Pos() tokens.Position { return lt.Token.Pos() }
+func (lt *ListType) End() tokens.Position { return lt.Rbrack.End() }
+func (lt *ListType) String() string       { return "[" + lt.Elem.String() + "]" }
+
+var _ TypeExpr = (*MapType)(nil)
+
+// MapType is a map from Key to Value, `{str: int}`.
+type MapType struct {
+	Token      tokens.Token
+	Key, Value TypeExpr
+	Rbrace     tokens.Token
+}
+
+func (mt *MapType) typeNode()            {}
+func (mt *MapType) TokenLiteral() string { return mt.Token.Literal }
+func (mt *MapType) Pos() tokens.Position { return mt.Token.Pos() }
+func (mt *MapType) End() tokens.Position { return mt.Rbrace.End() }
+func (mt *MapType) String() string {
+	return "{" + mt.Key.String() + ": " + mt.Value.String() + "}"
+}
+
+var _ TypeExpr = (*FunctionType)(nil)
+
+// FunctionType is the type of a function value, `f(int, int) -> int`.
+// Result is nil when the function has no declared result.
+type FunctionType struct {
+	Token  tokens.Token
+	Params []TypeExpr
+	Rparen tokens.Token
+	Result TypeExpr
+}
+
+func (ft *FunctionType) typeNode()            {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) Pos() tokens.Position { return ft.Token.Pos() }
+func (ft *FunctionType) End() tokens.Position {
+	if ft.Result != nil {
+		return ft.Result.End()
+	}
+	return ft.Rparen.End()
+}
+func (ft *FunctionType) String() string {
+	params := make([]string, len(ft.Params))
+	for i, p := range ft.Params {
+		params[i] = p.String()
+	}
+	s := "f(" + strings.Join(params, ", ") + ")"
+	if ft.Result != nil {
+		s += " -> " + ft.Result.String()
+	}
+	return s
+}
+
+var _ TypeExpr = (*OptionalType)(nil)
+
+// OptionalType is either an Elem or null, `int?`.
+type OptionalType struct {
+	Token tokens.Token
+	Elem  TypeExpr
+}
+
+func (ot *OptionalType) typeNode()            {}
+func (ot *OptionalType) TokenLiteral() string { return ot.Token.Literal }
+func (ot *OptionalType) Pos() tokens.Position { return posOr(ot.Elem, ot.Token) }
+func (ot *OptionalType) End() tokens.Position { return ot.Token.End() }
+func (ot *OptionalType) String() string       { return ot.Elem.String() + "?" }
+
+// posOr returns the position of n, or of t if n is missing, as it can be in
+// a tree with parse errors.
+func posOr(n Node, t tokens.Token) tokens.Position {
+	if isNil(n) {
+		return t.Pos()
+	}
+	return n.Pos()
+}
+
+// endOr returns the end of n, or of t if n is missing.
+func endOr(n Node, t tokens.Token) tokens.Position {
+	if isNil(n) {
+		return t.End()
+	}
+	return n.End()
+}
+
+// isNil reports whether n is missing, either nil or a nil pointer to a node.
+func isNil(n Node) bool {
+	if n == nil {
+		return true
+	}
+	v := reflect.ValueOf(n)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
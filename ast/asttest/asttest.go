@@ -0,0 +1,344 @@
+// Package asttest builds syntax trees for tests. The trees have the tokens
+// the parser would give them, without positions, so a parsed tree can be
+// checked against one built here with ast.Equal and ast.IgnorePositions.
+//
+//	want := asttest.Program(
+//		asttest.Assign("x", asttest.Infix(asttest.Int(1), "+", asttest.Ident("y"))),
+//	)
+//	if !ast.Equal(got, want, ast.IgnorePositions()) {
+package asttest
+
+import (
+	"strconv"
+
+	"github.com/joerdav/brev/ast"
+	"github.com/joerdav/brev/tokens"
+)
+
+// tok returns a token whose literal is its type, as for keywords, operators
+// and brackets.
+func tok(t tokens.TokenType) tokens.Token {
+	return tokens.Token{Type: t, Literal: string(t)}
+}
+
+// first returns the token an expression starts with.
+func first(e ast.Expression) tokens.Token {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		return e.Token
+	case *ast.IntLiteral:
+		return e.Token
+	case *ast.Boolean:
+		return e.Token
+	case *ast.StringLiteral:
+		return e.Token
+	case *ast.InfixExpression:
+		return first(e.Left)
+	case *ast.IndexExpression:
+		return first(e.Left)
+	case *ast.SelectorExpression:
+		return first(e.X)
+	case *ast.CallExpression:
+		return first(e.Function)
+	case *ast.PipeExpression:
+		return first(e.Left)
+	case *ast.RecordLiteral:
+		return first(e.Type)
+	case *ast.RangeExpression:
+		if e.Low != nil {
+			return first(e.Low)
+		}
+		return e.Token
+	case *ast.PrefixExpression:
+		return e.Token
+	case *ast.ArrayLiteral:
+		return e.Token
+	case *ast.MapLiteral:
+		return e.Token
+	case *ast.FunctionLiteral:
+		return e.Token
+	case *ast.MatchExpression:
+		return e.Token
+	}
+	return tokens.Token{}
+}
+
+// firstOfPattern returns the token a pattern starts with.
+func firstOfPattern(p ast.Pattern) tokens.Token {
+	switch p := p.(type) {
+	case *ast.WildcardPattern:
+		return p.Token
+	case *ast.BindingPattern:
+		return p.Token
+	case *ast.LiteralPattern:
+		return p.Token
+	case *ast.ArrayPattern:
+		return p.Token
+	case *ast.MapPattern:
+		return p.Token
+	}
+	return tokens.Token{}
+}
+
+// Program returns a program of the statements.
+func Program(stmts ...ast.Statement) *ast.Program {
+	return &ast.Program{Statements: stmts}
+}
+
+// Statements
+
+// Expr returns the expression as a statement.
+func Expr(e ast.Expression) *ast.ExpressionStatement {
+	return &ast.ExpressionStatement{Token: first(e), Expression: e}
+}
+
+// Assign returns `name = value`.
+func Assign(name string, value ast.Expression) *ast.AssignmentStatement {
+	return &ast.AssignmentStatement{Token: tok(tokens.ASSIGN), Name: Ident(name), Value: value}
+}
+
+// TypedAssign returns `name: typ = value`.
+func TypedAssign(name string, typ ast.TypeExpr, value ast.Expression) *ast.AssignmentStatement {
+	stmt := Assign(name, value)
+	stmt.Type = typ
+	return stmt
+}
+
+// AssignTo returns `target op= value`, op is empty for plain assignment.
+// An identifier target is the Name of the statement.
+func AssignTo(target ast.Expression, op string, value ast.Expression) *ast.AssignmentStatement {
+	stmt := &ast.AssignmentStatement{Token: tok(tokens.TokenType(op + "=")), Operator: op, Value: value}
+	if name, ok := target.(*ast.Identifier); ok {
+		stmt.Name = name
+	} else {
+		stmt.Target = target
+	}
+	return stmt
+}
+
+// PatternAssign returns `targets = values`.
+func PatternAssign(targets []ast.Pattern, values ...ast.Expression) *ast.PatternAssignmentStatement {
+	return &ast.PatternAssignmentStatement{Token: tok(tokens.ASSIGN), Targets: targets, Values: values}
+}
+
+// Block returns `{ stmts }`.
+func Block(stmts ...ast.Statement) *ast.BlockStatement {
+	return &ast.BlockStatement{Token: tok(tokens.LBRC), Statements: stmts, Rbrace: tok(tokens.RBRC)}
+}
+
+// For returns `l variable in iterable { body }`.
+func For(variable string, iterable ast.Expression, body ...ast.Statement) *ast.ForStatement {
+	return &ast.ForStatement{Token: tok(tokens.LOOP), Variable: Ident(variable), Iterable: iterable, Body: Block(body...)}
+}
+
+// TypeDecl returns `t name { fields }`.
+func TypeDecl(name string, fields ...*ast.Field) *ast.TypeDeclaration {
+	return &ast.TypeDeclaration{Token: tok(tokens.TYPE), Name: Ident(name), Fields: fields, Rbrace: tok(tokens.RBRC)}
+}
+
+// Field returns a field of a type declaration, typ may be nil.
+func Field(name string, typ ast.TypeExpr) *ast.Field {
+	return &ast.Field{Name: Ident(name), Type: typ}
+}
+
+// Import returns `im name "path"`, name may be empty.
+func Import(name, path string) *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: tok(tokens.IMPORT), Path: Str(path)}
+	if name != "" {
+		stmt.Name = Ident(name)
+	}
+	return stmt
+}
+
+// Expressions
+
+// Ident returns an identifier.
+func Ident(name string) *ast.Identifier {
+	return &ast.Identifier{Token: tokens.Token{Type: tokens.IDENT, Literal: name}, Value: name}
+}
+
+// Int returns an integer literal.
+func Int(v int64) *ast.IntLiteral {
+	return &ast.IntLiteral{Token: tokens.Token{Type: tokens.NUMBER, Literal: strconv.FormatInt(v, 10)}, Value: v}
+}
+
+// Str returns a string literal.
+func Str(s string) *ast.StringLiteral {
+	return &ast.StringLiteral{Token: tokens.Token{Type: tokens.STRING, Literal: s}, Value: s}
+}
+
+// Bool returns T or F.
+func Bool(v bool) *ast.Boolean {
+	if v {
+		return &ast.Boolean{Token: tok(tokens.TRUE), Value: true}
+	}
+	return &ast.Boolean{Token: tok(tokens.FALSE)}
+}
+
+// Prefix returns `op right`.
+func Prefix(op string, right ast.Expression) *ast.PrefixExpression {
+	return &ast.PrefixExpression{Token: tok(tokens.TokenType(op)), Operator: op, Right: right}
+}
+
+// Infix returns `left op right`.
+func Infix(left ast.Expression, op string, right ast.Expression) *ast.InfixExpression {
+	return &ast.InfixExpression{Token: tok(tokens.TokenType(op)), Operator: op, Left: left, Right: right}
+}
+
+// Array returns `[elems]`.
+func Array(elems ...ast.Expression) *ast.ArrayLiteral {
+	return &ast.ArrayLiteral{Token: tok(tokens.LSQB), Elements: elems, Rbrack: tok(tokens.RSQB)}
+}
+
+// Map returns `{pairs}`.
+func Map(pairs ...*ast.MapPair) *ast.MapLiteral {
+	return &ast.MapLiteral{Token: tok(tokens.LBRC), Pairs: pairs, Rbrace: tok(tokens.RBRC)}
+}
+
+// Pair returns `key: value` in a map literal.
+func Pair(key, value ast.Expression) *ast.MapPair {
+	return &ast.MapPair{Token: first(key), Key: key, Value: value}
+}
+
+// ShortPair returns the shorthand `name` in a map literal, for `"name": name`.
+func ShortPair(name string) *ast.MapPair {
+	id := Ident(name)
+	return &ast.MapPair{Token: id.Token, Key: &ast.StringLiteral{Token: id.Token, Value: name}, Value: id, Shorthand: true}
+}
+
+// Index returns `left[index]`.
+func Index(left, index ast.Expression) *ast.IndexExpression {
+	return &ast.IndexExpression{Token: tok(tokens.LSQB), Left: left, Index: index, Rbrack: tok(tokens.RSQB)}
+}
+
+// Func returns `f(params) -> result { body }`, result may be nil.
+func Func(params []*ast.Parameter, result ast.TypeExpr, body ...ast.Statement) *ast.FunctionLiteral {
+	return &ast.FunctionLiteral{Token: tok(tokens.FUNCTION), Parameters: params, ReturnType: result, Body: Block(body...)}
+}
+
+// Param returns a function parameter, typ may be nil.
+func Param(name string, typ ast.TypeExpr) *ast.Parameter {
+	return &ast.Parameter{Name: Ident(name), Type: typ}
+}
+
+// Record returns `typ{fields}`.
+func Record(typ ast.Expression, fields ...*ast.FieldValue) *ast.RecordLiteral {
+	return &ast.RecordLiteral{Token: tok(tokens.LBRC), Type: typ, Fields: fields, Rbrace: tok(tokens.RBRC)}
+}
+
+// FieldValue returns `name: value` in a record literal.
+func FieldValue(name string, value ast.Expression) *ast.FieldValue {
+	return &ast.FieldValue{Name: Ident(name), Value: value}
+}
+
+// ShortField returns the shorthand `name` in a record literal.
+func ShortField(name string) *ast.FieldValue {
+	id := Ident(name)
+	return &ast.FieldValue{Name: id, Value: id, Shorthand: true}
+}
+
+// Selector returns `x.sel`.
+func Selector(x ast.Expression, sel string) *ast.SelectorExpression {
+	return &ast.SelectorExpression{Token: tok(tokens.DOT), X: x, Sel: Ident(sel)}
+}
+
+// Call returns `function(args)`.
+func Call(function ast.Expression, args ...ast.Expression) *ast.CallExpression {
+	return &ast.CallExpression{Token: tok(tokens.LBRK), Function: function, Arguments: args, Rparen: tok(tokens.RBRK)}
+}
+
+// Pipe returns `left |> right`.
+func Pipe(left, right ast.Expression) *ast.PipeExpression {
+	return &ast.PipeExpression{Token: tok(tokens.PIPE), Left: left, Right: right}
+}
+
+// Range returns `low..high`, either bound may be nil.
+func Range(low, high ast.Expression) *ast.RangeExpression {
+	return &ast.RangeExpression{Token: tok(tokens.RANGE), Low: low, High: high}
+}
+
+// InclusiveRange returns `low..=high`, low may be nil.
+func InclusiveRange(low, high ast.Expression) *ast.RangeExpression {
+	return &ast.RangeExpression{Token: tok(tokens.RANGE_INCL), Low: low, High: high, Inclusive: true}
+}
+
+// Match returns `m subject { arms }`.
+func Match(subject ast.Expression, arms ...*ast.MatchArm) *ast.MatchExpression {
+	return &ast.MatchExpression{Token: tok(tokens.MATCH), Subject: subject, Arms: arms, Rbrace: tok(tokens.RBRC)}
+}
+
+// Arm returns `pattern i guard -> body`, guard may be nil.
+func Arm(pattern ast.Pattern, guard, body ast.Expression) *ast.MatchArm {
+	return &ast.MatchArm{Token: firstOfPattern(pattern), Pattern: pattern, Guard: guard, Body: body}
+}
+
+// Patterns
+
+// Wildcard returns `_`.
+func Wildcard() *ast.WildcardPattern {
+	return &ast.WildcardPattern{Token: tokens.Token{Type: tokens.IDENT, Literal: "_"}}
+}
+
+// Bind returns a pattern binding name.
+func Bind(name string) *ast.BindingPattern {
+	id := Ident(name)
+	return &ast.BindingPattern{Token: id.Token, Name: id}
+}
+
+// Literal returns a pattern matching the literal, which may be negated.
+func Literal(value ast.Expression) *ast.LiteralPattern {
+	return &ast.LiteralPattern{Token: first(value), Value: value}
+}
+
+// ArrayPattern returns `[elems]` as a pattern.
+func ArrayPattern(elems ...ast.Pattern) *ast.ArrayPattern {
+	return &ast.ArrayPattern{Token: tok(tokens.LSQB), Elements: elems, Rbrack: tok(tokens.RSQB)}
+}
+
+// MapPattern returns `{pairs}` as a pattern.
+func MapPattern(pairs ...*ast.MapPatternPair) *ast.MapPattern {
+	return &ast.MapPattern{Token: tok(tokens.LBRC), Pairs: pairs, Rbrace: tok(tokens.RBRC)}
+}
+
+// PatternPair returns `key: value` in a map pattern.
+func PatternPair(key ast.Expression, value ast.Pattern) *ast.MapPatternPair {
+	return &ast.MapPatternPair{Token: first(key), Key: key, Value: value}
+}
+
+// ShortPatternPair returns the shorthand `name` in a map pattern, binding
+// the value under "name" to name.
+func ShortPatternPair(name string) *ast.MapPatternPair {
+	var value ast.Pattern = Bind(name)
+	if name == "_" {
+		value = Wildcard()
+	}
+	id := Ident(name)
+	return &ast.MapPatternPair{Token: id.Token, Key: &ast.StringLiteral{Token: id.Token, Value: name}, Value: value, Shorthand: true}
+}
+
+// Types
+
+// Named returns a named type such as `int`.
+func Named(name string) *ast.NamedType {
+	return &ast.NamedType{Token: tokens.Token{Type: tokens.IDENT, Literal: name}, Name: name}
+}
+
+// List returns `[elem]`.
+func List(elem ast.TypeExpr) *ast.ListType {
+	return &ast.ListType{Token: tok(tokens.LSQB), Elem: elem, Rbrack: tok(tokens.RSQB)}
+}
+
+// MapOf returns `{key: value}`.
+func MapOf(key, value ast.TypeExpr) *ast.MapType {
+	return &ast.MapType{Token: tok(tokens.LBRC), Key: key, Value: value, Rbrace: tok(tokens.RBRC)}
+}
+
+// FuncType returns `f(params) -> result`, result may be nil.
+func FuncType(result ast.TypeExpr, params ...ast.TypeExpr) *ast.FunctionType {
+	return &ast.FunctionType{Token: tok(tokens.FUNCTION), Params: params, Rparen: tok(tokens.RBRK), Result: result}
+}
+
+// Optional returns `elem?`.
+func Optional(elem ast.TypeExpr) *ast.OptionalType {
+	return &ast.OptionalType{Token: tok(tokens.QUESTION), Elem: elem}
+}
@@ -0,0 +1,134 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/joerdav/brev/tokens"
+)
+
+var _ Node = (*CommentGroup)(nil)
+
+// CommentGroup is a sequence of comments on consecutive lines with no code
+// or blank lines between them. A comment after code on the same line is a
+// group of its own.
+type CommentGroup struct {
+	List []*Comment
+}
+
+// Text returns the text of the comments without their slashes, one line
+// per comment.
+func (g *CommentGroup) Text() string {
+	lines := make([]string, len(g.List))
+	for i, c := range g.List {
+		lines[i] = strings.TrimPrefix(c.Text(), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (g *CommentGroup) TokenLiteral() string { return g.List[0].TokenLiteral() }
+func (g *CommentGroup) Pos() tokens.Position { return g.List[0].Pos() }
+func (g *CommentGroup) End() tokens.Position { return g.List[len(g.List)-1].End() }
+func (g *CommentGroup) String() string {
+	lines := make([]string, len(g.List))
+	for i, c := range g.List {
+		lines[i] = c.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// NodeComments are the comments attached to a node. Leading is the group
+// on the lines directly above it and Trailing the comment after it on its
+// last line.
+type NodeComments struct {
+	Leading, Trailing *CommentGroup
+}
+
+// A CommentMap maps nodes to the comments describing them.
+type CommentMap map[Node]*NodeComments
+
+// NewCommentMap attaches each of comments to a node in the tree at node.
+//
+// A group following code on the same line trails the outermost node ending
+// just before it. Any other group leads the outermost node starting on the
+// line after it. Groups that are separated from code by a blank line, or
+// that are followed by a closing brace, aren't attached.
+func NewCommentMap(node Node, comments []*CommentGroup) CommentMap {
+	var nodes []Node
+	Inspect(node, func(n Node) bool {
+		switch n.(type) {
+		case nil, *Package, *Program, *CommentGroup, *Comment:
+		default:
+			if n.End() != (tokens.Position{}) {
+				nodes = append(nodes, n)
+			}
+		}
+		return true
+	})
+	cmap := CommentMap{}
+	for _, g := range comments {
+		if n := trailed(nodes, g); n != nil {
+			if c := cmap.get(n); c.Trailing == nil {
+				c.Trailing = g
+				continue
+			}
+		}
+		if n := led(nodes, g); n != nil {
+			if c := cmap.get(n); c.Leading == nil {
+				c.Leading = g
+			}
+		}
+	}
+	return cmap
+}
+
+func (cmap CommentMap) get(n Node) *NodeComments {
+	c, ok := cmap[n]
+	if !ok {
+		c = &NodeComments{}
+		cmap[n] = c
+	}
+	return c
+}
+
+// trailed returns the node g trails, nodes is in pre-order so the first of
+// several nodes ending at the same place is the outermost.
+func trailed(nodes []Node, g *CommentGroup) Node {
+	pos := g.Pos()
+	var trailed Node
+	for _, n := range nodes {
+		end := n.End()
+		if end.Row != pos.Row || pos.Before(end) {
+			continue
+		}
+		if trailed == nil || trailed.End().Before(end) {
+			trailed = n
+		}
+	}
+	if trailed == nil {
+		return nil
+	}
+	// Code starting between the node and the comment, such as the block
+	// in `g = f() { // comment`, means the comment doesn't describe it.
+	for _, n := range nodes {
+		if start := n.Pos(); !start.Before(trailed.End()) && start.Before(pos) {
+			return nil
+		}
+	}
+	return trailed
+}
+
+// led returns the node g leads.
+func led(nodes []Node, g *CommentGroup) Node {
+	end := g.End()
+	var led Node
+	for _, n := range nodes {
+		start := n.Pos()
+		if start.Row != end.Row+1 {
+			continue
+		}
+		if led == nil || start.Before(led.Pos()) {
+			led = n
+		}
+	}
+	return led
+}
@@ -0,0 +1,138 @@
+package ast
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// FprintTree writes node and its children to w as an indented tree, one
+// node per line with the field of its parent holding it, its type, its
+// operator or value, and its position.
+//
+//	Program
+//	  Statements[0]: ExpressionStatement 0:0-0:5
+//	    Expression: InfixExpression + 0:0-0:5
+//	      Left: IntLiteral 1 0:0-0:1
+//	      Right: IntLiteral 2 0:4-0:5
+func FprintTree(w io.Writer, node Node) error {
+	bw := bufio.NewWriter(w)
+	dump(node, func(depth int, c *Cursor) {
+		fmt.Fprint(bw, strings.Repeat("  ", depth))
+		if depth > 0 {
+			fmt.Fprint(bw, field(c)+": ")
+		}
+		fmt.Fprintln(bw, strings.Join(describe(c.Node()), " "))
+	}, nil)
+	return bw.Flush()
+}
+
+// FprintDot writes node and its children to w as a Graphviz digraph, with
+// an edge from each node to each of its children.
+func FprintDot(w io.Writer, node Node) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph AST {")
+	fmt.Fprintln(bw, "\tnode [shape=box, fontname=monospace];")
+	var ids []int
+	next := 0
+	dump(node, func(depth int, c *Cursor) {
+		id := next
+		next++
+		fmt.Fprintf(bw, "\tn%d [label=%s];\n", id, strconv.Quote(strings.Join(describe(c.Node()), "\n")))
+		if depth > 0 {
+			fmt.Fprintf(bw, "\tn%d -> n%d [label=%s];\n", ids[len(ids)-1], id, strconv.Quote(field(c)))
+		}
+		ids = append(ids, id)
+	}, func() {
+		ids = ids[:len(ids)-1]
+	})
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dump calls pre for each non-nil node in the tree at node, in the order
+// Walk visits them, then post once its children are done.
+func dump(node Node, pre func(depth int, c *Cursor), post func()) {
+	depth := 0
+	Apply(node, func(c *Cursor) bool {
+		if c.Node() == nil {
+			return false
+		}
+		pre(depth, c)
+		depth++
+		return true
+	}, func(c *Cursor) bool {
+		depth--
+		if post != nil {
+			post()
+		}
+		return true
+	})
+}
+
+// field describes where the cursor's node is in its parent, `Left` or
+// `Statements[2]`.
+func field(c *Cursor) string {
+	if i := c.Index(); i >= 0 {
+		return fmt.Sprintf("%s[%d]", c.Name(), i)
+	}
+	return c.Name()
+}
+
+// describe returns n's type with what distinguishes it from other nodes of
+// the same type, followed by its position.
+func describe(n Node) []string {
+	kind := strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast.")
+	var detail string
+	switch n := n.(type) {
+	case *Package:
+		return []string{kind}
+	case *Program:
+		if n.Filename != "" {
+			return []string{kind + " " + n.Filename}
+		}
+		return []string{kind}
+	case *Comment:
+		detail = strconv.Quote(n.Token.Literal)
+	case *Identifier:
+		detail = n.Value
+	case *IntLiteral:
+		detail = n.String()
+	case *StringLiteral:
+		detail = strconv.Quote(n.Value)
+	case *Boolean:
+		detail = n.String()
+	case *InfixExpression:
+		detail = n.Operator
+	case *PrefixExpression:
+		detail = n.Operator
+	case *AssignmentStatement:
+		detail = n.Operator + "="
+	case *RangeExpression:
+		detail = ".."
+		if n.Inclusive {
+			detail = "..="
+		}
+	case *NamedType:
+		detail = n.Name
+	case *MapPair:
+		if n.Shorthand {
+			detail = "shorthand"
+		}
+	case *FieldValue:
+		if n.Shorthand {
+			detail = "shorthand"
+		}
+	case *MapPatternPair:
+		if n.Shorthand {
+			detail = "shorthand"
+		}
+	}
+	if detail != "" {
+		kind += " " + detail
+	}
+	pos, end := n.Pos(), n.End()
+	return []string{kind, fmt.Sprintf("%d:%d-%d:%d", pos.Row, pos.Col, end.Row, end.Col)}
+}
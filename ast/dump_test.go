@@ -0,0 +1,90 @@
+package ast_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/joerdav/brev/ast"
+	brevparser "github.com/joerdav/brev/parser"
+	"github.com/matryer/is"
+)
+
+func TestFprintTree(t *testing.T) {
+	is := is.New(t)
+	program, err := brevparser.ParseString("x = -1 + n // c\nxs[1..=2]", brevparser.WithComments())
+	is.NoErr(err)
+	var buf bytes.Buffer
+	is.NoErr(ast.FprintTree(&buf, program))
+	expected := `Program
+  Statements[0]: AssignmentStatement = 0:0-0:10
+    Name: Identifier x 0:0-0:1
+    Value: InfixExpression + 0:4-0:10
+      Left: PrefixExpression - 0:4-0:6
+        Right: IntLiteral 1 0:5-0:6
+      Right: Identifier n 0:9-0:10
+  Statements[1]: ExpressionStatement 1:0-1:9
+    Expression: IndexExpression 1:0-1:9
+      Left: Identifier xs 1:0-1:2
+      Index: RangeExpression ..= 1:3-1:8
+        Low: IntLiteral 1 1:3-1:4
+        High: IntLiteral 2 1:7-1:8
+  Comments[0]: CommentGroup 0:11-0:15
+    List[0]: Comment "// c" 0:11-0:15
+`
+	is.Equal(expected, buf.String())
+}
+
+func TestFprintTreeLiterals(t *testing.T) {
+	is := is.New(t)
+	program, err := brevparser.ParseString("P{x, y: \"a\\\"b\"}\n{k, \"v\": T}")
+	is.NoErr(err)
+	var buf bytes.Buffer
+	is.NoErr(ast.FprintTree(&buf, program))
+	for _, line := range []string{
+		"Fields[0]: FieldValue shorthand 0:2-0:3",
+		`Value: StringLiteral "a\"b" 0:8-0:14`,
+		"Pairs[0]: MapPair shorthand 1:1-1:2",
+		"Value: Boolean T 1:9-1:10",
+	} {
+		is.True(strings.Contains(buf.String(), line+"\n")) // missing line
+	}
+}
+
+func TestDumpLeavesTree(t *testing.T) {
+	is := is.New(t)
+	// A shorthand field built without its Value.
+	record := func() ast.Node {
+		return &ast.RecordLiteral{
+			Type:   &ast.Identifier{Value: "P"},
+			Fields: []*ast.FieldValue{{Name: &ast.Identifier{Value: "x"}, Shorthand: true}},
+		}
+	}
+	tree, dot := record(), record()
+	is.NoErr(ast.FprintTree(&bytes.Buffer{}, tree))
+	is.NoErr(ast.FprintDot(&bytes.Buffer{}, dot))
+	is.True(ast.Equal(tree, record()))
+	is.True(ast.Equal(dot, record()))
+}
+
+func TestFprintDot(t *testing.T) {
+	is := is.New(t)
+	program, err := brevparser.ParseString(`a + "q"`)
+	is.NoErr(err)
+	var buf bytes.Buffer
+	is.NoErr(ast.FprintDot(&buf, program))
+	expected := `digraph AST {
+	node [shape=box, fontname=monospace];
+	n0 [label="Program"];
+	n1 [label="ExpressionStatement\n0:0-0:7"];
+	n0 -> n1 [label="Statements[0]"];
+	n2 [label="InfixExpression +\n0:0-0:7"];
+	n1 -> n2 [label="Expression"];
+	n3 [label="Identifier a\n0:0-0:1"];
+	n2 -> n3 [label="Left"];
+	n4 [label="StringLiteral \"q\"\n0:4-0:7"];
+	n2 -> n4 [label="Right"];
+}
+`
+	is.Equal(expected, buf.String())
+}
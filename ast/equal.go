@@ -0,0 +1,133 @@
+package ast
+
+import (
+	"math/big"
+	"reflect"
+
+	"github.com/joerdav/brev/tokens"
+)
+
+// An EqualOption changes what Equal compares.
+type EqualOption func(*equaler)
+
+// IgnorePositions makes Equal ignore where tokens are, their rows, columns
+// and files, how strings are written and the Filename of programs.
+func IgnorePositions() EqualOption {
+	return func(e *equaler) { e.ignorePositions = true }
+}
+
+// IgnoreLiterals makes Equal ignore the literal and raw text of tokens.
+// Values held by nodes, such as the Value of an IntLiteral or the Operator
+// of an InfixExpression, are still compared.
+func IgnoreLiterals() EqualOption {
+	return func(e *equaler) { e.ignoreLiterals = true }
+}
+
+// Equal reports whether the trees at a and b have the same shape: nodes of
+// the same types, with the same tokens and values, and the same comments
+// attached to them. A nil slice of nodes is equal to an empty one.
+//
+//	ast.Equal(got, want, ast.IgnorePositions())
+func Equal(a, b Node, opts ...EqualOption) bool {
+	var e equaler
+	for _, opt := range opts {
+		opt(&e)
+	}
+	return e.nodes(a, b)
+}
+
+type equaler struct {
+	ignorePositions, ignoreLiterals bool
+	// a and b are the comment maps of the programs being compared.
+	a, b CommentMap
+}
+
+var programType = reflect.TypeOf(Program{})
+
+// nodes compares two nodes, and the comments attached to them.
+func (e *equaler) nodes(a, b Node) bool {
+	if isNilNode(a) || isNilNode(b) {
+		return isNilNode(a) && isNilNode(b)
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() {
+		return false
+	}
+	if pa, ok := a.(*Program); ok {
+		defer func(a, b CommentMap) { e.a, e.b = a, b }(e.a, e.b)
+		e.a, e.b = pa.CommentMap, b.(*Program).CommentMap
+	}
+	t := va.Elem().Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type == commentMapType || e.ignorePositions && t == programType && f.Name == "Filename" {
+			// Comments in the map are compared with the nodes they are
+			// attached to, and the file name is part of a position.
+			continue
+		}
+		if !e.values(va.Elem().Field(i), vb.Elem().Field(i)) {
+			return false
+		}
+	}
+	ca, cb := e.a[a], e.b[b]
+	if ca == nil {
+		ca = &NodeComments{}
+	}
+	if cb == nil {
+		cb = &NodeComments{}
+	}
+	return e.nodes(ca.Leading, cb.Leading) && e.nodes(ca.Trailing, cb.Trailing)
+}
+
+// values compares two fields of nodes of the same type.
+func (e *equaler) values(a, b reflect.Value) bool {
+	switch {
+	case a.Type() == bigIntType:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() && b.IsNil()
+		}
+		return a.Interface().(*big.Int).Cmp(b.Interface().(*big.Int)) == 0
+	case a.Kind() == reflect.Interface || a.Kind() == reflect.Ptr:
+		return e.nodes(asNode(a), asNode(b))
+	case a.Type() == tokenType:
+		ta, tb := a.Interface().(tokens.Token), b.Interface().(tokens.Token)
+		if e.ignorePositions {
+			ta.Row, ta.Col, ta.File, ta.Raw = 0, 0, "", ""
+			tb.Row, tb.Col, tb.File, tb.Raw = 0, 0, "", ""
+		}
+		if e.ignoreLiterals {
+			ta.Literal, tb.Literal = "", ""
+			ta.Raw, tb.Raw = "", ""
+		}
+		return ta == tb
+	case a.Kind() == reflect.Slice:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !e.values(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a.Interface() == b.Interface()
+	}
+}
+
+// asNode returns the node held by the field v, nil if it holds none.
+func asNode(v reflect.Value) Node {
+	if v.IsNil() {
+		return nil
+	}
+	return v.Interface().(Node)
+}
+
+// isNilNode reports whether n is nil or a nil pointer.
+func isNilNode(n Node) bool {
+	if n == nil {
+		return true
+	}
+	v := reflect.ValueOf(n)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
@@ -0,0 +1,73 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/joerdav/brev/ast"
+	"github.com/joerdav/brev/ast/asttest"
+	brevparser "github.com/joerdav/brev/parser"
+	"github.com/matryer/is"
+)
+
+func TestEqual(t *testing.T) {
+	parse := func(src string) *ast.Program {
+		program, err := brevparser.ParseString(src, brevparser.WithComments())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return program
+	}
+	tests := []struct {
+		name     string
+		a, b     ast.Node
+		opts     []ast.EqualOption
+		expected bool
+	}{
+		{"same source", parse("x = a + 1"), parse("x = a + 1"), nil, true},
+		{"moved", parse("x = a + 1"), parse("\nx = a+1"), nil, false},
+		{"moved ignoring positions", parse("x = a + 1"), parse("\nx = a+1"), []ast.EqualOption{ast.IgnorePositions()}, true},
+		{"different operator", parse("x = a + 1"), parse("x = a - 1"), []ast.EqualOption{ast.IgnorePositions()}, false},
+		{"different value", parse("x = a + 1"), parse("x = a + 2"), []ast.EqualOption{ast.IgnorePositions(), ast.IgnoreLiterals()}, false},
+		{"different type", parse("x = a"), parse("x = 1"), []ast.EqualOption{ast.IgnorePositions()}, false},
+		{"nil field", parse("xs[..1]"), parse("xs[0..1]"), []ast.EqualOption{ast.IgnorePositions()}, false},
+		{"literal", asttest.Int(10), &ast.IntLiteral{Token: asttest.Int(10).Token, Value: 10}, nil, true},
+		{"literal text", asttest.Int(10), func() ast.Node {
+			n := asttest.Int(10)
+			n.Token.Literal = "010"
+			return n
+		}(), nil, false},
+		{"ignoring literals", asttest.Int(10), func() ast.Node {
+			n := asttest.Int(10)
+			n.Token.Literal = "010"
+			return n
+		}(), []ast.EqualOption{ast.IgnoreLiterals()}, true},
+		{"nil and empty lists", asttest.Array(), &ast.ArrayLiteral{
+			Token: asttest.Array().Token, Elements: []ast.Expression{}, Rbrack: asttest.Array().Rbrack,
+		}, nil, true},
+		{"comments", parse("x = 1 // one"), parse("x = 1 // one"), nil, true},
+		{"different comments", parse("x = 1 // one"), parse("x = 1 // two"), []ast.EqualOption{ast.IgnorePositions()}, false},
+		{"attached differently", parse("// a\nx = 1"), parse("// a\n\nx = 1"), []ast.EqualOption{ast.IgnorePositions()}, false},
+		{"nil", nil, (*ast.Identifier)(nil), nil, true},
+		{"nil and node", nil, asttest.Ident("x"), nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(ast.Equal(tt.a, tt.b, tt.opts...), tt.expected)
+			is.Equal(ast.Equal(tt.b, tt.a, tt.opts...), tt.expected)
+		})
+	}
+}
+
+func TestEqualIgnoresFilenames(t *testing.T) {
+	is := is.New(t)
+	a, err := brevparser.ParseString("x = 1")
+	is.NoErr(err)
+	b, err := brevparser.ParseString("x = 1")
+	is.NoErr(err)
+	b.Filename = "x.brev"
+	b.Statements[0].(*ast.AssignmentStatement).Token.File = "x.brev"
+	is.True(!ast.Equal(a, b))
+	is.True(ast.Equal(a, b, ast.IgnorePositions()))
+	is.True(ast.Equal(b, asttest.Program(asttest.Assign("x", asttest.Int(1))), ast.IgnorePositions()))
+}
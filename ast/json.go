@@ -0,0 +1,323 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/joerdav/brev/tokens"
+)
+
+// kinds maps the "kind" of each node in the JSON form to its type.
+var kinds = map[string]reflect.Type{}
+
+func init() {
+	for _, n := range []Node{
+		(*Package)(nil), (*Program)(nil), (*CommentGroup)(nil), (*Comment)(nil),
+		(*ImportStatement)(nil), (*AssignmentStatement)(nil), (*PatternAssignmentStatement)(nil),
+		(*BlockStatement)(nil), (*ForStatement)(nil), (*TypeDeclaration)(nil), (*Field)(nil),
+		(*ExpressionStatement)(nil), (*Identifier)(nil), (*InfixExpression)(nil),
+		(*PrefixExpression)(nil), (*IntLiteral)(nil), (*Boolean)(nil), (*StringLiteral)(nil),
+		(*ArrayLiteral)(nil), (*MapLiteral)(nil), (*MapPair)(nil), (*IndexExpression)(nil),
+		(*FunctionLiteral)(nil), (*Parameter)(nil), (*RecordLiteral)(nil), (*FieldValue)(nil),
+		(*SelectorExpression)(nil), (*CallExpression)(nil), (*PipeExpression)(nil),
+		(*RangeExpression)(nil), (*MatchExpression)(nil), (*MatchArm)(nil),
+		(*WildcardPattern)(nil), (*BindingPattern)(nil), (*LiteralPattern)(nil),
+		(*ArrayPattern)(nil), (*MapPattern)(nil), (*MapPatternPair)(nil),
+		(*NamedType)(nil), (*ListType)(nil), (*MapType)(nil), (*FunctionType)(nil),
+		(*OptionalType)(nil),
+	} {
+		t := reflect.TypeOf(n).Elem()
+		kinds[t.Name()] = t
+	}
+}
+
+var (
+	nodeType   = reflect.TypeOf((*Node)(nil)).Elem()
+	tokenType  = reflect.TypeOf(tokens.Token{})
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+)
+
+// jsonToken is the JSON form of a tokens.Token.
+type jsonToken struct {
+	Type    tokens.TokenType `json:"type"`
+	Literal string           `json:"literal"`
+	Row     int              `json:"row"`
+	Col     int              `json:"col"`
+	File    string           `json:"file,omitempty"`
+	Raw     string           `json:"raw,omitempty"`
+}
+
+// jsonPosition is the JSON form of a tokens.Position.
+type jsonPosition struct {
+	Row  int    `json:"row"`
+	Col  int    `json:"col"`
+	File string `json:"file,omitempty"`
+}
+
+var commentMapType = reflect.TypeOf(CommentMap(nil))
+
+// MarshalJSON returns the JSON encoding of node. Each node is an object with
+// a "kind" naming its type, `"kind": "InfixExpression"`, its fields under
+// their names with the first letter lowered, and its "pos" and "end" from
+// Node.Pos and Node.End. Rows and columns count from 0, tokens hold the
+// position they start at and a string's "raw" source text.
+//
+//	{"kind": "Identifier", "pos": {"row": 0, "col": 0}, "end": {"row": 0, "col": 1},
+//		"token": {"type": "ident", "literal": "x", "row": 0, "col": 0}, "value": "x"}
+//
+// Comments in a program's CommentMap are held by the nodes they are attached
+// to, under "leadingComments" and "trailingComments".
+func MarshalJSON(node Node) ([]byte, error) {
+	var e encoder
+	v, err := e.toJSON(reflect.ValueOf(node))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+type encoder struct {
+	// cmap is the comment map of the program being encoded.
+	cmap CommentMap
+}
+
+// toJSON converts v to a value encoding/json can marshal, nodes become maps
+// holding their kind.
+func (e *encoder) toJSON(v reflect.Value) (interface{}, error) {
+	switch {
+	case v.Type() == bigIntType:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return v.Interface(), nil
+	case v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Kind() == reflect.Interface {
+			return e.toJSON(v.Elem())
+		}
+		t := v.Elem().Type()
+		if kinds[t.Name()] != t {
+			return nil, fmt.Errorf("ast: cannot marshal %s", v.Type())
+		}
+		if program, ok := v.Interface().(*Program); ok {
+			defer func(cmap CommentMap) { e.cmap = cmap }(e.cmap)
+			e.cmap = program.CommentMap
+		}
+		obj := map[string]interface{}{"kind": t.Name()}
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).Type == commentMapType {
+				// The map itself is only recorded as present, its
+				// entries are on the nodes.
+				if !v.Elem().Field(i).IsNil() {
+					obj[jsonName(t.Field(i).Name)] = struct{}{}
+				}
+				continue
+			}
+			field, err := e.toJSON(v.Elem().Field(i))
+			if err != nil {
+				return nil, err
+			}
+			obj[jsonName(t.Field(i).Name)] = field
+		}
+		node := v.Interface().(Node)
+		obj["pos"], obj["end"] = toJSONPosition(node.Pos()), toJSONPosition(node.End())
+		if c := e.cmap[node]; c != nil {
+			var err error
+			if obj["leadingComments"], err = e.toJSON(reflect.ValueOf(c.Leading)); err != nil {
+				return nil, err
+			}
+			if obj["trailingComments"], err = e.toJSON(reflect.ValueOf(c.Trailing)); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	case v.Type() == tokenType:
+		t := v.Interface().(tokens.Token)
+		return jsonToken{Type: t.Type, Literal: t.Literal, Row: t.Row, Col: t.Col, File: t.File, Raw: t.Raw}, nil
+	case v.Kind() == reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			elem, err := e.toJSON(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			list[i] = elem
+		}
+		return list, nil
+	default:
+		return v.Interface(), nil
+	}
+}
+
+func toJSONPosition(p tokens.Position) jsonPosition {
+	return jsonPosition{Row: p.Row, Col: p.Col, File: p.File}
+}
+
+// Unmarshal decodes a node encoded by MarshalJSON. The "pos" and "end" of
+// nodes are ignored, they are found again from the tokens.
+func Unmarshal(data []byte) (Node, error) {
+	var d decoder
+	v := reflect.New(nodeType).Elem()
+	if err := d.fromJSON(data, v); err != nil {
+		return nil, err
+	}
+	if v.IsNil() {
+		return nil, fmt.Errorf("ast: cannot unmarshal null into a node")
+	}
+	return v.Interface().(Node), nil
+}
+
+type decoder struct {
+	// cmap collects the comments attached to nodes of the program being
+	// decoded.
+	cmap CommentMap
+}
+
+// fromJSON decodes data into v, which is a node, token, slice or basic
+// field of a node.
+func (d *decoder) fromJSON(data json.RawMessage, v reflect.Value) error {
+	switch {
+	case v.Type() == bigIntType:
+		if isNull(data) {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		n := new(big.Int)
+		if err := json.Unmarshal(data, n); err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(n))
+		return nil
+	case v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr:
+		if isNull(data) {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		var kind string
+		if err := json.Unmarshal(obj["kind"], &kind); err != nil {
+			return fmt.Errorf("ast: node has no kind")
+		}
+		t, ok := kinds[kind]
+		if !ok {
+			return fmt.Errorf("ast: unknown kind %q", kind)
+		}
+		node := reflect.New(t)
+		if !node.Type().AssignableTo(v.Type()) {
+			return fmt.Errorf("ast: %s is not a valid %s", kind, v.Type())
+		}
+		program, isProgram := node.Interface().(*Program)
+		if isProgram {
+			defer func(cmap CommentMap) { d.cmap = cmap }(d.cmap)
+			d.cmap = CommentMap{}
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field, ok := obj[jsonName(t.Field(i).Name)]
+			if !ok || t.Field(i).Type == commentMapType {
+				continue
+			}
+			if err := d.fromJSON(field, node.Elem().Field(i)); err != nil {
+				return err
+			}
+		}
+		if err := d.attach(node.Interface().(Node), obj); err != nil {
+			return err
+		}
+		if isProgram && !isNull(obj["commentMap"]) {
+			program.CommentMap = d.cmap
+			program.relinkComments()
+		}
+		v.Set(node)
+		return nil
+	case v.Type() == tokenType:
+		var t jsonToken
+		if err := json.Unmarshal(data, &t); err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(tokens.Token{Type: t.Type, Literal: t.Literal, Row: t.Row, Col: t.Col, File: t.File, Raw: t.Raw}))
+		return nil
+	case v.Kind() == reflect.Slice:
+		var list []json.RawMessage
+		if err := json.Unmarshal(data, &list); err != nil {
+			return err
+		}
+		if list == nil {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		v.Set(reflect.MakeSlice(v.Type(), len(list), len(list)))
+		for i, elem := range list {
+			if err := d.fromJSON(elem, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return json.Unmarshal(data, v.Addr().Interface())
+	}
+}
+
+// attach records the comments held by the JSON object for node.
+func (d *decoder) attach(node Node, obj map[string]json.RawMessage) error {
+	var c NodeComments
+	for key, g := range map[string]**CommentGroup{"leadingComments": &c.Leading, "trailingComments": &c.Trailing} {
+		if data, ok := obj[key]; ok {
+			if err := d.fromJSON(data, reflect.ValueOf(g).Elem()); err != nil {
+				return err
+			}
+		}
+	}
+	if (c.Leading != nil || c.Trailing != nil) && d.cmap != nil {
+		d.cmap[node] = &c
+	}
+	return nil
+}
+
+// relinkComments replaces the decoded copies of comment groups in Doc and
+// CommentMap with the groups in Comments they were encoded from.
+func (p *Program) relinkComments() {
+	groups := map[tokens.Position]*CommentGroup{}
+	for _, g := range p.Comments {
+		groups[g.Pos()] = g
+	}
+	relink := func(g *CommentGroup) *CommentGroup {
+		if same, ok := groups[g.Pos()]; ok {
+			return same
+		}
+		return g
+	}
+	if p.Doc != nil {
+		p.Doc = relink(p.Doc)
+	}
+	for _, c := range p.CommentMap {
+		if c.Leading != nil {
+			c.Leading = relink(c.Leading)
+		}
+		if c.Trailing != nil {
+			c.Trailing = relink(c.Trailing)
+		}
+	}
+}
+
+func isNull(data json.RawMessage) bool {
+	return len(data) == 0 || bytes.Equal(bytes.TrimSpace(data), []byte("null"))
+}
+
+// jsonName is the JSON key for the node field name, `Token` is "token".
+func jsonName(field string) string {
+	r, n := utf8.DecodeRuneInString(field)
+	return string(unicode.ToLower(r)) + field[n:]
+}
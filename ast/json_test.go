@@ -0,0 +1,156 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/joerdav/brev/ast"
+	brevparser "github.com/joerdav/brev/parser"
+	"github.com/matryer/is"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	program, err := brevparser.ParseString(everyNode, brevparser.WithComments())
+	if err != nil {
+		t.Fatal(err)
+	}
+	program.Filename = "every.brev"
+	data, err := ast.MarshalJSON(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := ast.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Map keys are compared by identity, so the comment map is compared by
+	// the positions of its nodes.
+	ignoreMap := cmpopts.IgnoreFields(ast.Program{}, "CommentMap")
+	if diff := cmp.Diff(program, node, ignoreMap, cmp.Comparer(bigIntsEqual)); diff != "" {
+		t.Fatal(diff)
+	}
+	if diff := cmp.Diff(comments(program), comments(node.(*ast.Program))); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func bigIntsEqual(a, b *big.Int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(b) == 0
+}
+
+// comments returns the comments in the program's comment map keyed by the
+// type and position of the node they are attached to.
+func comments(program *ast.Program) map[string]string {
+	m := map[string]string{}
+	for n, c := range program.CommentMap {
+		key := fmt.Sprintf("%T %s", n, n.Pos())
+		if c.Leading != nil {
+			m[key+" leading"] = c.Leading.Text()
+		}
+		if c.Trailing != nil {
+			m[key+" trailing"] = c.Trailing.Text()
+		}
+	}
+	return m
+}
+
+func TestJSONComments(t *testing.T) {
+	is := is.New(t)
+	program, err := brevparser.ParseString("// doc\n\n// leads\nx = 1 // trails\n\n// alone\n", brevparser.WithComments())
+	is.NoErr(err)
+	data, err := ast.MarshalJSON(program)
+	is.NoErr(err)
+	node, err := ast.Unmarshal(data)
+	is.NoErr(err)
+	got := node.(*ast.Program)
+	is.True(got.Doc == got.Comments[0])
+	c := got.CommentMap[got.Statements[0]]
+	is.True(c.Leading == got.Comments[1])
+	is.True(c.Trailing == got.Comments[2])
+	is.Equal(len(got.Unattached()), 1)
+	is.Equal(got.Unattached()[0].Text(), "alone")
+
+	program, err = brevparser.ParseString("x = 1")
+	is.NoErr(err)
+	data, err = ast.MarshalJSON(program)
+	is.NoErr(err)
+	node, err = ast.Unmarshal(data)
+	is.NoErr(err)
+	is.Equal(node.(*ast.Program).CommentMap, nil)
+}
+
+func TestMarshalJSON(t *testing.T) {
+	is := is.New(t)
+	program, err := brevparser.ParseString("-x")
+	is.NoErr(err)
+	data, err := ast.MarshalJSON(program.Statements[0].(*ast.ExpressionStatement).Expression)
+	is.NoErr(err)
+	var got interface{}
+	is.NoErr(json.Unmarshal(data, &got))
+	var expected interface{}
+	is.NoErr(json.Unmarshal([]byte(`{
+		"kind": "PrefixExpression",
+		"pos": {"row": 0, "col": 0},
+		"end": {"row": 0, "col": 2},
+		"token": {"type": "-", "literal": "-", "row": 0, "col": 0},
+		"operator": "-",
+		"right": {
+			"kind": "Identifier",
+			"pos": {"row": 0, "col": 1},
+			"end": {"row": 0, "col": 2},
+			"token": {"type": "ident", "literal": "x", "row": 0, "col": 1},
+			"value": "x"
+		}
+	}`), &expected))
+	is.Equal(expected, got)
+}
+
+func TestMarshalJSONPositions(t *testing.T) {
+	is := is.New(t)
+	program, err := brevparser.ParseString("g = f(a) {\n\ta\n}")
+	is.NoErr(err)
+	data, err := ast.MarshalJSON(program)
+	is.NoErr(err)
+	type position struct{ Row, Col int }
+	var got struct {
+		Statements []struct {
+			Value struct {
+				Pos, End   position
+				Parameters []struct{ Pos, End position }
+			}
+		}
+	}
+	is.NoErr(json.Unmarshal(data, &got))
+	fn := got.Statements[0].Value
+	is.Equal(fn.Pos, position{0, 4})
+	is.Equal(fn.End, position{2, 1})
+	is.Equal(fn.Parameters[0].Pos, position{0, 6})
+	is.Equal(fn.Parameters[0].End, position{0, 7})
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		input, expected string
+	}{
+		{`null`, "ast: cannot unmarshal null into a node"},
+		{`{"value": "x"}`, "ast: node has no kind"},
+		{`{"kind": "Nope"}`, `ast: unknown kind "Nope"`},
+		{`{"kind": "ExpressionStatement", "expression": {"kind": "NamedType"}}`, "ast: NamedType is not a valid ast.Expression"},
+		{`{"kind": "ForStatement", "body": {"kind": "Identifier"}}`, "ast: Identifier is not a valid *ast.BlockStatement"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			_, err := ast.Unmarshal([]byte(tt.input))
+			is.True(err != nil)
+			is.Equal(tt.expected, err.Error())
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package ast_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/joerdav/brev/ast"
+	brevparser "github.com/joerdav/brev/parser"
+	"github.com/joerdav/brev/tokens"
+	"github.com/matryer/is"
+)
+
+// source returns the text of src between the node's Pos and End.
+func source(src string, n ast.Node) string {
+	lines := strings.SplitAfter(src, "\n")
+	pos, end := n.Pos(), n.End()
+	if pos.Row == end.Row {
+		return string([]rune(lines[pos.Row])[pos.Col:end.Col])
+	}
+	text := string([]rune(lines[pos.Row])[pos.Col:])
+	for row := pos.Row + 1; row < end.Row; row++ {
+		text += lines[row]
+	}
+	return text + string([]rune(lines[end.Row])[:end.Col])
+}
+
+func TestPosEnd(t *testing.T) {
+	tests := []struct {
+		input    string
+		node     func(*ast.Program) ast.Node
+		expected string
+	}{
+		{"a + b * c", func(p *ast.Program) ast.Node { return p.Statements[0] }, "a + b * c"},
+		{"x = -foo(1, [2])", func(p *ast.Program) ast.Node { return p.Statements[0].(*ast.AssignmentStatement).Value }, "-foo(1, [2])"},
+		{`s = "a\"b"`, func(p *ast.Program) ast.Node { return p.Statements[0] }, `s = "a\"b"`},
+		{"xs[1..]", func(p *ast.Program) ast.Node {
+			return p.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression).Index
+		}, "1.."},
+		{"x: {str: [int]}? = {}", func(p *ast.Program) ast.Node { return p.Statements[0].(*ast.AssignmentStatement).Type }, "{str: [int]}?"},
+		{"g = f(a, b: int) {\n\ta\n}", func(p *ast.Program) ast.Node { return p.Statements[0].(*ast.AssignmentStatement).Value }, "f(a, b: int) {\n\ta\n}"},
+		{"q = P{x}.x", func(p *ast.Program) ast.Node { return p.Statements[0].(*ast.AssignmentStatement).Value }, "P{x}.x"},
+		{"s = \"a\tb\" + c", func(p *ast.Program) ast.Node { return p.Statements[0] }, "s = \"a\tb\" + c"},
+		{"s = \"a\nb\" + c\nd", func(p *ast.Program) ast.Node { return p.Statements[0] }, "s = \"a\nb\" + c"},
+		{`s = "\q\n" + c`, func(p *ast.Program) ast.Node { return p.Statements[0] }, `s = "\q\n" + c`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			program, err := brevparser.ParseString(tt.input)
+			is.NoErr(err)
+			is.Equal(source(tt.input, tt.node(program)), tt.expected)
+		})
+	}
+}
+
+func TestPosEndEveryNode(t *testing.T) {
+	program, err := brevparser.ParseString(everyNode, brevparser.WithComments())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast.Inspect(program, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		if !n.Pos().Before(n.End()) {
+			t.Errorf("%T %s: Pos %s is not before End %s", n, n, n.Pos(), n.End())
+			return true
+		}
+		// A range that starts or ends on whitespace is off by at least one.
+		text := source(everyNode, n)
+		if strings.TrimSpace(text) != text {
+			t.Errorf("%T %s: source %q has surrounding space", n, n, text)
+		}
+		return true
+	})
+	pos, end := program.Pos(), program.End()
+	is := is.New(t)
+	is.Equal(fmt.Sprint(pos), "1:0")
+	is.Equal(fmt.Sprint(end), fmt.Sprintf("%d:1", strings.Count(everyNode, "\n")-1))
+}
+
+func TestPosEndMissingChildren(t *testing.T) {
+	op := tokens.Token{Type: tokens.ADD, Literal: "+", Row: 1, Col: 2}
+	x := &ast.Identifier{Token: tokens.Token{Type: tokens.IDENT, Literal: "x", Row: 1, Col: 0}, Value: "x"}
+	tests := []struct {
+		node     ast.Node
+		pos, end string
+	}{
+		{&ast.InfixExpression{Token: op, Operator: "+", Left: x}, "1:0", "1:3"},
+		{&ast.InfixExpression{Token: op, Operator: "+"}, "1:2", "1:3"},
+		{&ast.PrefixExpression{Token: op, Operator: "+"}, "1:2", "1:3"},
+		{&ast.ExpressionStatement{Token: op}, "1:2", "1:3"},
+		{&ast.AssignmentStatement{Token: op, Name: x}, "1:0", "1:3"},
+		{&ast.ForStatement{Token: op, Variable: x}, "1:2", "1:3"},
+		{&ast.SelectorExpression{Token: op, X: x}, "1:0", "1:3"},
+		{&ast.MatchArm{Token: op}, "1:2", "1:3"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%T", tt.node), func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(tt.node.Pos().String(), tt.pos)
+			is.Equal(tt.node.End().String(), tt.end)
+		})
+	}
+}
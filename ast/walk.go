@@ -0,0 +1,242 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// walkChild walks n unless it is missing, as children can be in a tree with
+// parse errors.
+func walkChild(v Visitor, n Node) {
+	if !isNil(n) {
+		Walk(v, n)
+	}
+}
+
+func walkStatements(v Visitor, list []Statement) {
+	for _, s := range list {
+		walkChild(v, s)
+	}
+}
+
+func walkExpressions(v Visitor, list []Expression) {
+	for _, e := range list {
+		walkChild(v, e)
+	}
+}
+
+func walkPatterns(v Visitor, list []Pattern) {
+	for _, p := range list {
+		walkChild(v, p)
+	}
+}
+
+func walkTypes(v Visitor, list []TypeExpr) {
+	for _, t := range list {
+		walkChild(v, t)
+	}
+}
+
+// Walk traverses an AST in depth-first order: It starts by calling
+// v.Visit(node); node must not be nil. If the visitor w returned by
+// v.Visit(node) is not nil, Walk is invoked recursively with visitor
+// w for each of the non-nil children of node, followed by a call of
+// w.Visit(nil). Nil children, such as the missing operand of an
+// InfixExpression in a tree with parse errors, are skipped.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Package:
+		for _, f := range n.Files {
+			walkChild(v, f)
+		}
+
+	case *Program:
+		walkStatements(v, n.Statements)
+		for _, c := range n.Comments {
+			walkChild(v, c)
+		}
+
+	case *CommentGroup:
+		for _, c := range n.List {
+			walkChild(v, c)
+		}
+
+	case *Comment, *Identifier, *IntLiteral, *Boolean, *StringLiteral,
+		*WildcardPattern, *NamedType:
+		// nothing to do
+
+	// Statements
+	case *ImportStatement:
+		walkChild(v, n.Name)
+		walkChild(v, n.Path)
+
+	case *AssignmentStatement:
+		walkChild(v, n.Name)
+		walkChild(v, n.Target)
+		walkChild(v, n.Type)
+		walkChild(v, n.Value)
+
+	case *PatternAssignmentStatement:
+		walkPatterns(v, n.Targets)
+		walkExpressions(v, n.Values)
+
+	case *ExpressionStatement:
+		walkChild(v, n.Expression)
+
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+
+	case *ForStatement:
+		walkChild(v, n.Variable)
+		walkChild(v, n.Iterable)
+		walkChild(v, n.Body)
+
+	case *TypeDeclaration:
+		walkChild(v, n.Name)
+		for _, f := range n.Fields {
+			walkChild(v, f)
+		}
+
+	case *Field:
+		walkChild(v, n.Name)
+		walkChild(v, n.Type)
+
+	// Expressions
+	case *InfixExpression:
+		walkChild(v, n.Left)
+		walkChild(v, n.Right)
+
+	case *PrefixExpression:
+		walkChild(v, n.Right)
+
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+
+	case *MapLiteral:
+		for _, p := range n.Pairs {
+			walkChild(v, p)
+		}
+
+	case *MapPair:
+		walkChild(v, n.Key)
+		walkChild(v, n.Value)
+
+	case *IndexExpression:
+		walkChild(v, n.Left)
+		walkChild(v, n.Index)
+
+	case *FunctionLiteral:
+		for _, p := range n.Parameters {
+			walkChild(v, p)
+		}
+		walkChild(v, n.ReturnType)
+		walkChild(v, n.Body)
+
+	case *Parameter:
+		walkChild(v, n.Name)
+		walkChild(v, n.Type)
+
+	case *RecordLiteral:
+		walkChild(v, n.Type)
+		for _, f := range n.Fields {
+			walkChild(v, f)
+		}
+
+	case *FieldValue:
+		walkChild(v, n.Name)
+		if !n.Shorthand {
+			walkChild(v, n.Value)
+		}
+
+	case *SelectorExpression:
+		walkChild(v, n.X)
+		walkChild(v, n.Sel)
+
+	case *CallExpression:
+		walkChild(v, n.Function)
+		walkExpressions(v, n.Arguments)
+
+	case *PipeExpression:
+		walkChild(v, n.Left)
+		walkChild(v, n.Right)
+
+	case *RangeExpression:
+		walkChild(v, n.Low)
+		walkChild(v, n.High)
+
+	case *MatchExpression:
+		walkChild(v, n.Subject)
+		for _, a := range n.Arms {
+			walkChild(v, a)
+		}
+
+	case *MatchArm:
+		walkChild(v, n.Pattern)
+		walkChild(v, n.Guard)
+		walkChild(v, n.Body)
+
+	// Patterns
+	case *BindingPattern:
+		walkChild(v, n.Name)
+
+	case *LiteralPattern:
+		walkChild(v, n.Value)
+
+	case *ArrayPattern:
+		walkPatterns(v, n.Elements)
+
+	case *MapPattern:
+		for _, p := range n.Pairs {
+			walkChild(v, p)
+		}
+
+	case *MapPatternPair:
+		walkChild(v, n.Key)
+		walkChild(v, n.Value)
+
+	// Types
+	case *ListType:
+		walkChild(v, n.Elem)
+
+	case *MapType:
+		walkChild(v, n.Key)
+		walkChild(v, n.Value)
+
+	case *FunctionType:
+		walkTypes(v, n.Params)
+		walkChild(v, n.Result)
+
+	case *OptionalType:
+		walkChild(v, n.Elem)
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: It starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a
+// call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,182 @@
+package ast_test
+
+import (
+	"fmt"
+	goast "go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/joerdav/brev/ast"
+	brevparser "github.com/joerdav/brev/parser"
+	"github.com/joerdav/brev/tokens"
+	"github.com/matryer/is"
+)
+
+// everyNode uses every construct in the language, when adding a node type add
+// source using it here.
+const everyNode = `// comment
+im util "lib/util"
+t Point { x: int, y }
+total: int = 0
+limit = 18446744073709551616
+scale = f(p: Point, by: int?, fs: [f(int) -> int], counts: {str: int}) -> Point {
+	Point{x: p.x * by, y}
+}
+a, [b, _], {c, "d": d2} = 1, pair, dict
+xs[0] += -1
+p.x = !T
+l n in 1..=10 { total += n }
+ys = [1, 2][..1]
+lookup = {"k": 1, v}
+data |> util.sum() |> g
+m value {
+	0 -> "zero",
+	-1 -> F,
+	[x, y] -> x + y,
+	{"k": k, rest} i k > 0 -> k,
+	_ -> 0..3,
+}
+`
+
+// nodeTypes returns the name of every type in package ast that implements
+// Node, found by reading the package source.
+func nodeTypes(t *testing.T) []string {
+	t.Helper()
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range pkgs["ast"].Files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*goast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Name.Name != "TokenLiteral" {
+				continue
+			}
+			star := fn.Recv.List[0].Type.(*goast.StarExpr)
+			names = append(names, star.X.(*goast.Ident).Name)
+		}
+	}
+	return names
+}
+
+func TestWalkVisitsEveryNodeType(t *testing.T) {
+	program, err := brevparser.ParseString(everyNode, brevparser.WithComments())
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	ast.Inspect(&ast.Package{Files: []*ast.Program{program}}, func(n ast.Node) bool {
+		if n != nil {
+			seen[strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast.")] = true
+		}
+		return true
+	})
+	names := nodeTypes(t)
+	if len(names) < 40 {
+		t.Fatalf("expected to find every node type, found %v", names)
+	}
+	for _, name := range names {
+		if !seen[name] {
+			t.Errorf("ast.Walk never visited %s, it must be handled by Walk and used in everyNode", name)
+		}
+	}
+}
+
+type recorder struct {
+	events *[]string
+}
+
+func (r recorder) Visit(n ast.Node) ast.Visitor {
+	if n == nil {
+		*r.events = append(*r.events, "end")
+		return nil
+	}
+	*r.events = append(*r.events, fmt.Sprintf("%T %s", n, n))
+	return r
+}
+
+func TestWalkOrder(t *testing.T) {
+	is := is.New(t)
+	program, err := brevparser.ParseString("x = -1 + y")
+	is.NoErr(err)
+	var events []string
+	ast.Walk(recorder{&events}, program)
+	expected := []string{
+		"*ast.Program x = -1+y",
+		"*ast.AssignmentStatement x = -1+y",
+		"*ast.Identifier x",
+		"end",
+		"*ast.InfixExpression -1+y",
+		"*ast.PrefixExpression -1",
+		"*ast.IntLiteral 1",
+		"end",
+		"end",
+		"*ast.Identifier y",
+		"end",
+		"end",
+		"end",
+		"end",
+	}
+	is.Equal(events, expected)
+}
+
+func TestInspectPrunes(t *testing.T) {
+	is := is.New(t)
+	program, err := brevparser.ParseString("add = f(a, b) { a + b }\nc = add(1, 2)")
+	is.NoErr(err)
+	var idents []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.Identifier:
+			idents = append(idents, n.Value)
+		}
+		return true
+	})
+	is.Equal(idents, []string{"add", "c", "add"})
+}
+
+func TestWalkSkipsMissingChildren(t *testing.T) {
+	is := is.New(t)
+	x := &ast.Identifier{Value: "x"}
+	var missing *ast.Identifier
+	program := &ast.Program{Statements: []ast.Statement{
+		&ast.ExpressionStatement{Expression: &ast.InfixExpression{Left: x}},
+		&ast.ExpressionStatement{Expression: &ast.ArrayLiteral{Elements: []ast.Expression{nil, missing, x}}},
+		&ast.ExpressionStatement{},
+	}}
+	var visited []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		if n != nil {
+			visited = append(visited, fmt.Sprintf("%T", n))
+		}
+		return true
+	})
+	is.Equal(visited, []string{
+		"*ast.Program",
+		"*ast.ExpressionStatement", "*ast.InfixExpression", "*ast.Identifier",
+		"*ast.ExpressionStatement", "*ast.ArrayLiteral", "*ast.Identifier",
+		"*ast.ExpressionStatement",
+	})
+}
+
+func TestWalkPanicsOnUnknownNode(t *testing.T) {
+	is := is.New(t)
+	defer func() {
+		r := recover()
+		is.Equal(r, "ast.Walk: unexpected node type *ast_test.unknown")
+	}()
+	ast.Inspect(&unknown{}, func(ast.Node) bool { return true })
+}
+
+type unknown struct{}
+
+func (*unknown) TokenLiteral() string { return "" }
+func (*unknown) String() string       { return "" }
+func (*unknown) Pos() tokens.Position { return tokens.Position{} }
+func (*unknown) End() tokens.Position { return tokens.Position{} }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joerdav/brev/ast"
+	"github.com/matryer/is"
+)
+
+func TestASTJSON(t *testing.T) {
+	is := is.New(t)
+	name := filepath.Join(t.TempDir(), "prog.brev")
+	is.NoErr(os.WriteFile(name, []byte("// c\nx = 1\n"), 0o644))
+	var out bytes.Buffer
+	is.NoErr(runAST([]string{"--json", name}, nil, &out, &out))
+	node, err := ast.Unmarshal(out.Bytes())
+	is.NoErr(err)
+	program := node.(*ast.Program)
+	is.Equal(program.Filename, name)
+	is.Equal(len(program.Comments), 1)
+	is.Equal(program.Statements[0].(*ast.AssignmentStatement).Token.File, name)
+}
+
+func TestASTFormats(t *testing.T) {
+	tests := []struct {
+		format, expected string
+	}{
+		{"tree", "Program\n  Statements[0]: AssignmentStatement = 0:0-0:5\n"},
+		{"dot", "digraph AST {\n"},
+		{"json", "{\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			is := is.New(t)
+			var out bytes.Buffer
+			is.NoErr(runAST([]string{"--format=" + tt.format}, strings.NewReader("x = 1"), &out, &out))
+			is.True(strings.HasPrefix(out.String(), tt.expected))
+		})
+	}
+}
+
+func TestASTErrors(t *testing.T) {
+	is := is.New(t)
+	var out bytes.Buffer
+	err := runAST(nil, strings.NewReader("x = 1"), &out, &out)
+	is.Equal(err.Error(), "ast: no output format given")
+	err = runAST([]string{"-json"}, strings.NewReader("x = "), &out, &out)
+	is.True(err != nil)
+	err = runAST([]string{"-format=xml"}, strings.NewReader("x = 1"), &out, &out)
+	is.Equal(err.Error(), `ast: unknown format "xml", expected json, dot or tree`)
+	err = runAST([]string{"-json", "-format=dot"}, strings.NewReader("x = 1"), &out, &out)
+	is.Equal(err.Error(), "ast: -json conflicts with -format=dot")
+}
@@ -0,0 +1,998 @@
+// Package evaluator runs Brev programs by walking their syntax trees.
+package evaluator
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"unicode/utf8"
+
+	"github.com/joerdav/brev/ast"
+	"github.com/joerdav/brev/object"
+	"github.com/joerdav/brev/tokens"
+)
+
+var (
+	NULL  = &object.Null{}
+	TRUE  = &object.Boolean{Value: true}
+	FALSE = &object.Boolean{Value: false}
+)
+
+// DefaultMaxDepth is the depth of function calls a program may reach when
+// no other limit is given with WithMaxDepth.
+const DefaultMaxDepth = 10000
+
+// Option configures the evaluation of a program by Eval.
+type Option func(*evaluator)
+
+// WithMaxSteps limits the number of nodes evaluated to n. By default there
+// is no limit.
+func WithMaxSteps(n int) Option {
+	return func(e *evaluator) {
+		e.maxSteps = n
+	}
+}
+
+// WithMaxDepth limits the depth of function calls to n, a call deeper than
+// n fails with a stack overflow. By default the limit is DefaultMaxDepth.
+func WithMaxDepth(n int) Option {
+	return func(e *evaluator) {
+		e.maxDepth = n
+	}
+}
+
+// WithMaxObjects limits the number of objects created to n, including
+// objects that are no longer used. By default there is no limit.
+func WithMaxObjects(n int) Option {
+	return func(e *evaluator) {
+		e.maxObjects = n
+	}
+}
+
+type evaluator struct {
+	// done is closed when the context of the evaluation is cancelled.
+	done <-chan struct{}
+	ctx  context.Context
+
+	steps, maxSteps     int
+	depth, maxDepth     int
+	objects, maxObjects int
+}
+
+// Eval evaluates node in env and returns its value. A program, block or
+// function body is the value of its last statement, and an assignment is the
+// value assigned. Failures are returned as an *object.Error, which stops the
+// evaluation of everything around it.
+//
+// Function calls, loop iterations and match arms run in a new environment
+// enclosed by the one they are in, holding the parameters, loop variable or
+// names bound by the pattern. Functions close over the environment they are
+// defined in. Assignment rebinds the nearest variable of the same name, if
+// there is one, otherwise it creates a variable in the current environment.
+//
+// An error holds the token of the innermost node that failed, and the calls
+// it was returned through so it can be printed as a stack trace.
+//
+// Evaluation stops with an error as soon as ctx is cancelled, or a limit set
+// by opts is passed.
+func Eval(ctx context.Context, node ast.Node, env *object.Environment, opts ...Option) object.Object {
+	e := &evaluator{ctx: ctx, done: ctx.Done(), maxDepth: DefaultMaxDepth}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e.eval(node, env)
+}
+
+// eval evaluates node as a step of the program, and positions any error it
+// fails with.
+func (e *evaluator) eval(node ast.Node, env *object.Environment) object.Object {
+	var result object.Object
+	select {
+	case <-e.done:
+		result = object.Errorf("%s", e.ctx.Err())
+	default:
+		e.steps++
+		if e.maxSteps > 0 && e.steps > e.maxSteps {
+			result = object.Errorf("step limit exceeded: %d", e.maxSteps)
+		} else {
+			result = e.evalNode(node, env)
+		}
+	}
+	if err, ok := result.(*object.Error); ok && err.Token.Type == "" {
+		err.Token = nodeToken(node)
+	}
+	return result
+}
+
+// track counts obj against the limit of objects created, it returns an
+// error instead of obj once the limit is passed.
+func (e *evaluator) track(obj object.Object) object.Object {
+	switch obj {
+	case NULL, TRUE, FALSE:
+		return obj
+	}
+	if isError(obj) {
+		return obj
+	}
+	e.objects++
+	if e.maxObjects > 0 && e.objects > e.maxObjects {
+		return object.Errorf("object limit exceeded: %d", e.maxObjects)
+	}
+	return obj
+}
+
+func (e *evaluator) evalNode(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	// Statements
+	case *ast.Program:
+		return e.evalStatements(node.Statements, env)
+	case *ast.BlockStatement:
+		return e.evalStatements(node.Statements, env)
+	case *ast.ExpressionStatement:
+		return e.eval(node.Expression, env)
+	case *ast.AssignmentStatement:
+		return e.evalAssignment(node, env)
+	case *ast.PatternAssignmentStatement:
+		return e.evalPatternAssignment(node, env)
+	case *ast.ForStatement:
+		return e.evalFor(node, env)
+	case *ast.TypeDeclaration:
+		rt := &object.RecordType{Name: node.Name.Value}
+		for _, f := range node.Fields {
+			rt.Fields = append(rt.Fields, f.Name.Value)
+		}
+		return env.Set(rt.Name, rt)
+	case *ast.ImportStatement:
+		return object.Errorf("imports are not supported when evaluating")
+
+	// Expressions
+	case *ast.IntLiteral:
+		if node.Big != nil {
+			return e.track(&object.BigInteger{Value: node.Big})
+		}
+		return e.track(&object.Integer{Value: node.Value})
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+	case *ast.StringLiteral:
+		return e.track(&object.String{Value: node.Value})
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+	case *ast.PrefixExpression:
+		right := e.eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return e.track(evalPrefixExpression(node.Operator, right))
+	case *ast.InfixExpression:
+		left := e.eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		right := e.eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return e.track(evalInfixExpression(node.Operator, left, right))
+	case *ast.ArrayLiteral:
+		elements := e.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return e.track(&object.Array{Elements: elements})
+	case *ast.MapLiteral:
+		return e.evalMapLiteral(node, env)
+	case *ast.IndexExpression:
+		left := e.eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := e.eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		result := evalIndexExpression(left, index)
+		if index.Type() == object.RANGE_OBJ || left.Type() == object.STRING_OBJ {
+			// Slices and characters of strings are new objects.
+			return e.track(result)
+		}
+		return result
+	case *ast.RangeExpression:
+		return e.evalRange(node, env)
+	case *ast.FunctionLiteral:
+		return e.track(&object.Function{Parameters: node.Parameters, Body: node.Body, Env: env})
+	case *ast.CallExpression:
+		function := e.eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args := e.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		return e.applyFunction(functionName(node.Function), node.Token, function, args)
+	case *ast.PipeExpression:
+		return e.evalPipe(node, env)
+	case *ast.RecordLiteral:
+		return e.evalRecordLiteral(node, env)
+	case *ast.SelectorExpression:
+		x := e.eval(node.X, env)
+		if isError(x) {
+			return x
+		}
+		return evalSelector(x, node.Sel.Value)
+	case *ast.MatchExpression:
+		return e.evalMatch(node, env)
+	}
+	return object.Errorf("cannot evaluate %T", node)
+}
+
+func (e *evaluator) evalStatements(stmts []ast.Statement, env *object.Environment) object.Object {
+	var result object.Object = NULL
+	for _, stmt := range stmts {
+		result = e.eval(stmt, env)
+		if isError(result) {
+			return result
+		}
+	}
+	return result
+}
+
+func (e *evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	result := make([]object.Object, 0, len(exps))
+	for _, exp := range exps {
+		evaluated := e.eval(exp, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+	return result
+}
+
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+	return object.Errorf("identifier not found: %s", node.Value)
+}
+
+func (e *evaluator) evalAssignment(node *ast.AssignmentStatement, env *object.Environment) object.Object {
+	value := e.eval(node.Value, env)
+	if isError(value) {
+		return value
+	}
+	if node.Name != nil {
+		if node.Operator != "" {
+			current := evalIdentifier(node.Name, env)
+			if isError(current) {
+				return current
+			}
+			if value = evalInfixExpression(node.Operator, current, value); isError(value) {
+				return value
+			}
+		}
+		return env.Assign(node.Name.Value, value)
+	}
+	switch target := node.Target.(type) {
+	case *ast.IndexExpression:
+		left := e.eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := e.eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+		if node.Operator != "" {
+			current := evalIndexExpression(left, index)
+			if isError(current) {
+				return current
+			}
+			if value = evalInfixExpression(node.Operator, current, value); isError(value) {
+				return value
+			}
+		}
+		return setIndex(left, index, value)
+	case *ast.SelectorExpression:
+		x := e.eval(target.X, env)
+		if isError(x) {
+			return x
+		}
+		if node.Operator != "" {
+			current := evalSelector(x, target.Sel.Value)
+			if isError(current) {
+				return current
+			}
+			if value = evalInfixExpression(node.Operator, current, value); isError(value) {
+				return value
+			}
+		}
+		return setField(x, target.Sel.Value, value)
+	}
+	return object.Errorf("cannot assign to %s", node.Target)
+}
+
+func setIndex(left, index, value object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		elements := left.(*object.Array).Elements
+		i := intValue(index)
+		if i < 0 || i >= int64(len(elements)) {
+			return object.Errorf("index out of range: %s with length %d", index.Inspect(), len(elements))
+		}
+		elements[i] = value
+		return value
+	case left.Type() == object.MAP_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return object.Errorf("unusable as map key: %s", index.Type())
+		}
+		left.(*object.Map).Set(key, value)
+		return value
+	}
+	return object.Errorf("cannot assign to index of %s with %s", left.Type(), index.Type())
+}
+
+func setField(x object.Object, name string, value object.Object) object.Object {
+	record, ok := x.(*object.Record)
+	if !ok {
+		return object.Errorf("cannot assign to field %s of %s", name, x.Type())
+	}
+	if !record.RecordType.HasField(name) {
+		return object.Errorf("%s has no field %s", record.RecordType.Name, name)
+	}
+	record.Fields[name] = value
+	return value
+}
+
+func (e *evaluator) evalPatternAssignment(node *ast.PatternAssignmentStatement, env *object.Environment) object.Object {
+	values := e.evalExpressions(node.Values, env)
+	if len(values) == 1 && isError(values[0]) {
+		return values[0]
+	}
+	bindings := map[string]object.Object{}
+	for i, target := range node.Targets {
+		if !e.matchPattern(target, values[i], bindings, env) {
+			return object.Errorf("cannot assign %s to %s", values[i].Inspect(), target)
+		}
+	}
+	for name, value := range bindings {
+		env.Assign(name, value)
+	}
+	return values[len(values)-1]
+}
+
+func (e *evaluator) evalFor(node *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := e.eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+	body := func(value object.Object) object.Object {
+		if isError(value) {
+			return value
+		}
+		scope := object.NewEnclosedEnvironment(env)
+		scope.Set(node.Variable.Value, value)
+		return e.eval(node.Body, scope)
+	}
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		for _, elem := range iterable.Elements {
+			if result := body(elem); isError(result) {
+				return result
+			}
+		}
+	case *object.Range:
+		if !iterable.HasHigh {
+			return object.Errorf("cannot loop over a range without an end")
+		}
+		low, last := iterable.Low, iterable.High
+		if !iterable.Inclusive {
+			if low >= last {
+				break
+			}
+			last--
+		}
+		// The loop stops at last rather than after it, so that a range
+		// ending at the largest int doesn't overflow.
+		for i := low; i <= last; i++ {
+			if result := body(e.track(&object.Integer{Value: i})); isError(result) {
+				return result
+			}
+			if i == last {
+				break
+			}
+		}
+	case *object.Map:
+		for _, k := range iterable.Keys {
+			if result := body(iterable.Pairs[k].Key); isError(result) {
+				return result
+			}
+		}
+	case *object.String:
+		for _, r := range iterable.Value {
+			if result := body(e.track(&object.String{Value: string(r)})); isError(result) {
+				return result
+			}
+		}
+	default:
+		return object.Errorf("cannot loop over %s", iterable.Type())
+	}
+	return NULL
+}
+
+func evalPrefixExpression(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return nativeBoolToBooleanObject(!isTruthy(right))
+	case "-":
+		switch right := right.(type) {
+		case *object.Integer:
+			if right.Value != math.MinInt64 {
+				return &object.Integer{Value: -right.Value}
+			}
+			return object.NewInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+		case *object.BigInteger:
+			return object.NewInteger(new(big.Int).Neg(right.Value))
+		}
+		return object.Errorf("unknown operator: -%s", right.Type())
+	}
+	return object.Errorf("unknown operator: %s%s", operator, right.Type())
+}
+
+func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerInfixExpression(operator, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ && operator == "+":
+		return &object.String{Value: left.(*object.String).Value + right.(*object.String).Value}
+	case operator == "==":
+		return nativeBoolToBooleanObject(equal(left, right))
+	case operator == "!=":
+		return nativeBoolToBooleanObject(!equal(left, right))
+	case left.Type() != right.Type():
+		return object.Errorf("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	}
+	return object.Errorf("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+// evalIntegerInfixExpression applies operator to two ints. Arithmetic is done
+// on int64s, and on big ints when either operand is one or the result would
+// overflow.
+func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	l, lok := left.(*object.Integer)
+	r, rok := right.(*object.Integer)
+	if lok && rok {
+		if result, ok := evalSmallIntegerInfixExpression(operator, l.Value, r.Value); ok {
+			return result
+		}
+	}
+	return evalBigIntegerInfixExpression(operator, toBig(left), toBig(right))
+}
+
+// evalSmallIntegerInfixExpression applies operator to two int64s, it returns
+// false if the result overflows.
+func evalSmallIntegerInfixExpression(operator string, left, right int64) (object.Object, bool) {
+	switch operator {
+	case "+":
+		sum := left + right
+		return &object.Integer{Value: sum}, (sum > left) == (right > 0)
+	case "-":
+		diff := left - right
+		return &object.Integer{Value: diff}, (diff < left) == (right > 0)
+	case "*":
+		if left == 0 || right == 0 {
+			return &object.Integer{Value: 0}, true
+		}
+		product := left * right
+		overflow := product/right != left || left == -1 && right == math.MinInt64 || right == -1 && left == math.MinInt64
+		return &object.Integer{Value: product}, !overflow
+	case "/", "%":
+		if right == 0 {
+			return object.Errorf("division by zero"), true
+		}
+		if left == math.MinInt64 && right == -1 {
+			return nil, false
+		}
+		if operator == "/" {
+			return &object.Integer{Value: left / right}, true
+		}
+		return &object.Integer{Value: left % right}, true
+	case "<":
+		return nativeBoolToBooleanObject(left < right), true
+	case ">":
+		return nativeBoolToBooleanObject(left > right), true
+	case "==":
+		return nativeBoolToBooleanObject(left == right), true
+	case "!=":
+		return nativeBoolToBooleanObject(left != right), true
+	}
+	return object.Errorf("unknown operator: %s %s %s", object.INTEGER_OBJ, operator, object.INTEGER_OBJ), true
+}
+
+func evalBigIntegerInfixExpression(operator string, left, right *big.Int) object.Object {
+	switch operator {
+	case "+":
+		return object.NewInteger(new(big.Int).Add(left, right))
+	case "-":
+		return object.NewInteger(new(big.Int).Sub(left, right))
+	case "*":
+		return object.NewInteger(new(big.Int).Mul(left, right))
+	case "/", "%":
+		if right.Sign() == 0 {
+			return object.Errorf("division by zero")
+		}
+		// Quo and Rem truncate like int64 division does.
+		if operator == "/" {
+			return object.NewInteger(new(big.Int).Quo(left, right))
+		}
+		return object.NewInteger(new(big.Int).Rem(left, right))
+	case "<":
+		return nativeBoolToBooleanObject(left.Cmp(right) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(left.Cmp(right) > 0)
+	case "==":
+		return nativeBoolToBooleanObject(left.Cmp(right) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(left.Cmp(right) != 0)
+	}
+	return object.Errorf("unknown operator: %s %s %s", object.INTEGER_OBJ, operator, object.INTEGER_OBJ)
+}
+
+// toBig returns the value of an int object as a big int.
+func toBig(obj object.Object) *big.Int {
+	if i, ok := obj.(*object.BigInteger); ok {
+		return i.Value
+	}
+	return big.NewInt(obj.(*object.Integer).Value)
+}
+
+// intValue returns the value of an int object as an int64. A big int is
+// clamped to the nearest int64, which is out of range of any index.
+func intValue(obj object.Object) int64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value
+	case *object.BigInteger:
+		if obj.Value.Sign() < 0 {
+			return math.MinInt64
+		}
+		return math.MaxInt64
+	}
+	return 0
+}
+
+// equal reports whether two objects hold the same value. Lists, maps and
+// records are compared by their contents, functions and types by identity.
+func equal(a, b object.Object) bool {
+	return equalSeen(a, b, map[[2]object.Object]bool{})
+}
+
+// equalSeen is equal, where seen holds the pairs of lists, maps and records
+// being compared. A pair met again inside itself is taken to be equal, so
+// values that contain themselves are compared without recursing forever.
+func equalSeen(a, b object.Object, seen map[[2]object.Object]bool) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a.(type) {
+	case *object.Array, *object.Map, *object.Record:
+		pair := [2]object.Object{a, b}
+		if seen[pair] {
+			return true
+		}
+		seen[pair] = true
+	}
+	switch a := a.(type) {
+	case *object.Integer:
+		b, ok := b.(*object.Integer)
+		return ok && a.Value == b.Value
+	case *object.BigInteger:
+		b, ok := b.(*object.BigInteger)
+		return ok && a.Value.Cmp(b.Value) == 0
+	case *object.Boolean:
+		return a.Value == b.(*object.Boolean).Value
+	case *object.String:
+		return a.Value == b.(*object.String).Value
+	case *object.Null:
+		return true
+	case *object.Range:
+		return *a == *b.(*object.Range)
+	case *object.Array:
+		b := b.(*object.Array)
+		if len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !equalSeen(a.Elements[i], b.Elements[i], seen) {
+				return false
+			}
+		}
+		return true
+	case *object.Map:
+		b := b.(*object.Map)
+		if len(a.Keys) != len(b.Keys) {
+			return false
+		}
+		for k, pair := range a.Pairs {
+			other, ok := b.Pairs[k]
+			if !ok || !equalSeen(pair.Value, other.Value, seen) {
+				return false
+			}
+		}
+		return true
+	case *object.Record:
+		b := b.(*object.Record)
+		if a.RecordType != b.RecordType {
+			return false
+		}
+		for name, value := range a.Fields {
+			if !equalSeen(value, b.Fields[name], seen) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func (e *evaluator) evalMapLiteral(node *ast.MapLiteral, env *object.Environment) object.Object {
+	m := object.NewMap()
+	for _, pair := range node.Pairs {
+		key := e.eval(pair.Key, env)
+		if isError(key) {
+			return key
+		}
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return object.Errorf("unusable as map key: %s", key.Type())
+		}
+		value := e.eval(pair.Value, env)
+		if isError(value) {
+			return value
+		}
+		m.Set(hashKey, value)
+	}
+	return e.track(m)
+}
+
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		elements := left.(*object.Array).Elements
+		i := intValue(index)
+		if i < 0 || i >= int64(len(elements)) {
+			return object.Errorf("index out of range: %s with length %d", index.Inspect(), len(elements))
+		}
+		return elements[i]
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.RANGE_OBJ:
+		elements := left.(*object.Array).Elements
+		low, high, err := bounds(index.(*object.Range), len(elements))
+		if err != nil {
+			return err
+		}
+		return &object.Array{Elements: append([]object.Object(nil), elements[low:high]...)}
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		runes := []rune(left.(*object.String).Value)
+		i := intValue(index)
+		if i < 0 || i >= int64(len(runes)) {
+			return object.Errorf("index out of range: %s with length %d", index.Inspect(), len(runes))
+		}
+		return &object.String{Value: string(runes[i])}
+	case left.Type() == object.STRING_OBJ && index.Type() == object.RANGE_OBJ:
+		s := left.(*object.String).Value
+		runes := []rune(s)
+		low, high, err := bounds(index.(*object.Range), utf8.RuneCountInString(s))
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: string(runes[low:high])}
+	case left.Type() == object.MAP_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return object.Errorf("unusable as map key: %s", index.Type())
+		}
+		if value, ok := left.(*object.Map).Get(key); ok {
+			return value
+		}
+		return NULL
+	}
+	return object.Errorf("index operator not supported: %s[%s]", left.Type(), index.Type())
+}
+
+// bounds returns the indexes a range slices from a sequence of length n.
+func bounds(r *object.Range, n int) (low, high int, err *object.Error) {
+	h := int64(n)
+	if r.HasHigh {
+		h = r.High
+		if r.Inclusive {
+			h++
+		}
+	}
+	if r.Low < 0 || h > int64(n) || r.Low > h {
+		return 0, 0, object.Errorf("slice bounds out of range: %s with length %d", r.Inspect(), n)
+	}
+	return int(r.Low), int(h), nil
+}
+
+func (e *evaluator) evalRange(node *ast.RangeExpression, env *object.Environment) object.Object {
+	r := &object.Range{Inclusive: node.Inclusive}
+	if node.Low != nil {
+		low := e.eval(node.Low, env)
+		if isError(low) {
+			return low
+		}
+		if low.Type() != object.INTEGER_OBJ {
+			return object.Errorf("range bounds must be int, got %s", low.Type())
+		}
+		if _, ok := low.(*object.BigInteger); ok {
+			return object.Errorf("range bound out of range: %s", low.Inspect())
+		}
+		r.Low = intValue(low)
+	}
+	if node.High != nil {
+		high := e.eval(node.High, env)
+		if isError(high) {
+			return high
+		}
+		if high.Type() != object.INTEGER_OBJ {
+			return object.Errorf("range bounds must be int, got %s", high.Type())
+		}
+		if _, ok := high.(*object.BigInteger); ok {
+			return object.Errorf("range bound out of range: %s", high.Inspect())
+		}
+		r.High, r.HasHigh = intValue(high), true
+	}
+	return e.track(r)
+}
+
+// applyFunction calls fn, named name, at the call token. An error returned
+// from the body records the call in its stack.
+func (e *evaluator) applyFunction(name string, call tokens.Token, fn object.Object, args []object.Object) object.Object {
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return object.Errorf("not a function: %s", fn.Type())
+	}
+	if len(args) != len(function.Parameters) {
+		return object.Errorf("wrong number of arguments: want %d, got %d", len(function.Parameters), len(args))
+	}
+	if e.maxDepth > 0 && e.depth >= e.maxDepth {
+		return object.Errorf("stack overflow: call depth exceeds %d", e.maxDepth)
+	}
+	e.depth++
+	defer func() { e.depth-- }()
+	env := object.NewEnclosedEnvironment(function.Env)
+	for i, param := range function.Parameters {
+		env.Set(param.Name.Value, args[i])
+	}
+	result := e.eval(function.Body, env)
+	if err, ok := result.(*object.Error); ok {
+		err.Stack = append(err.Stack, object.Frame{Function: name, Call: call})
+	}
+	return result
+}
+
+// functionName returns the name a function is called by in stack traces,
+// "anonymous" if it isn't called by a name.
+func functionName(e ast.Expression) string {
+	switch e := e.(type) {
+	case *ast.Identifier, *ast.SelectorExpression:
+		return e.String()
+	}
+	return "anonymous"
+}
+
+// evalPipe calls the right of the pipe with the left as its first argument,
+// `x |> g(y)` is `g(x, y)` and `x |> g` is `g(x)`.
+func (e *evaluator) evalPipe(node *ast.PipeExpression, env *object.Environment) object.Object {
+	left := e.eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	function, args := node.Right, []ast.Expression(nil)
+	if call, ok := node.Right.(*ast.CallExpression); ok {
+		function, args = call.Function, call.Arguments
+	}
+	fn := e.eval(function, env)
+	if isError(fn) {
+		return fn
+	}
+	rest := e.evalExpressions(args, env)
+	if len(rest) == 1 && isError(rest[0]) {
+		return rest[0]
+	}
+	return e.applyFunction(functionName(function), node.Token, fn, append([]object.Object{left}, rest...))
+}
+
+func (e *evaluator) evalRecordLiteral(node *ast.RecordLiteral, env *object.Environment) object.Object {
+	typ := e.eval(node.Type, env)
+	if isError(typ) {
+		return typ
+	}
+	rt, ok := typ.(*object.RecordType)
+	if !ok {
+		return object.Errorf("%s is not a type", node.Type)
+	}
+	record := &object.Record{RecordType: rt, Fields: map[string]object.Object{}}
+	for _, name := range rt.Fields {
+		record.Fields[name] = NULL
+	}
+	for _, field := range node.Fields {
+		if !rt.HasField(field.Name.Value) {
+			return object.Errorf("%s has no field %s", rt.Name, field.Name.Value)
+		}
+		value := e.eval(field.Value, env)
+		if isError(value) {
+			return value
+		}
+		record.Fields[field.Name.Value] = value
+	}
+	return e.track(record)
+}
+
+func evalSelector(x object.Object, name string) object.Object {
+	record, ok := x.(*object.Record)
+	if !ok {
+		return object.Errorf("cannot select field %s of %s", name, x.Type())
+	}
+	if !record.RecordType.HasField(name) {
+		return object.Errorf("%s has no field %s", record.RecordType.Name, name)
+	}
+	return record.Fields[name]
+}
+
+func (e *evaluator) evalMatch(node *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := e.eval(node.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+	for _, arm := range node.Arms {
+		bindings := map[string]object.Object{}
+		if !e.matchPattern(arm.Pattern, subject, bindings, env) {
+			continue
+		}
+		scope := object.NewEnclosedEnvironment(env)
+		for name, value := range bindings {
+			scope.Set(name, value)
+		}
+		if arm.Guard != nil {
+			guard := e.eval(arm.Guard, scope)
+			if isError(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+		return e.eval(arm.Body, scope)
+	}
+	return object.Errorf("no match arm matched %s", subject.Inspect())
+}
+
+// matchPattern reports whether value matches pattern, adding the names the
+// pattern binds to bindings.
+func (e *evaluator) matchPattern(pattern ast.Pattern, value object.Object, bindings map[string]object.Object, env *object.Environment) bool {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		return true
+	case *ast.BindingPattern:
+		bindings[pattern.Name.Value] = value
+		return true
+	case *ast.LiteralPattern:
+		literal := e.eval(pattern.Value, env)
+		return !isError(literal) && equal(literal, value)
+	case *ast.ArrayPattern:
+		array, ok := value.(*object.Array)
+		if !ok || len(array.Elements) != len(pattern.Elements) {
+			return false
+		}
+		for i, elem := range pattern.Elements {
+			if !e.matchPattern(elem, array.Elements[i], bindings, env) {
+				return false
+			}
+		}
+		return true
+	case *ast.MapPattern:
+		for _, pair := range pattern.Pairs {
+			key := e.eval(pair.Key, env)
+			if isError(key) {
+				return false
+			}
+			v, ok := lookup(value, key)
+			if !ok || !e.matchPattern(pair.Value, v, bindings, env) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// lookup returns the value of key in a map, or of the field named by key in
+// a record, so map patterns can take records apart.
+func lookup(container, key object.Object) (object.Object, bool) {
+	switch container := container.(type) {
+	case *object.Map:
+		if key, ok := key.(object.Hashable); ok {
+			return container.Get(key)
+		}
+	case *object.Record:
+		if name, ok := key.(*object.String); ok && container.RecordType.HasField(name.Value) {
+			return container.Fields[name.Value], true
+		}
+	}
+	return nil, false
+}
+
+// nodeToken returns the token an error in node is reported at.
+func nodeToken(node ast.Node) tokens.Token {
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		return node.Token
+	case *ast.AssignmentStatement:
+		return node.Token
+	case *ast.PatternAssignmentStatement:
+		return node.Token
+	case *ast.BlockStatement:
+		return node.Token
+	case *ast.ForStatement:
+		return node.Token
+	case *ast.TypeDeclaration:
+		return node.Token
+	case *ast.ImportStatement:
+		return node.Token
+	case *ast.Identifier:
+		return node.Token
+	case *ast.IntLiteral:
+		return node.Token
+	case *ast.StringLiteral:
+		return node.Token
+	case *ast.Boolean:
+		return node.Token
+	case *ast.FunctionLiteral:
+		return node.Token
+	case *ast.PrefixExpression:
+		return node.Token
+	case *ast.InfixExpression:
+		return node.Token
+	case *ast.ArrayLiteral:
+		return node.Token
+	case *ast.MapLiteral:
+		return node.Token
+	case *ast.IndexExpression:
+		return node.Token
+	case *ast.RangeExpression:
+		return node.Token
+	case *ast.CallExpression:
+		return node.Token
+	case *ast.PipeExpression:
+		return node.Token
+	case *ast.RecordLiteral:
+		return node.Token
+	case *ast.SelectorExpression:
+		return node.Token
+	case *ast.MatchExpression:
+		return node.Token
+	}
+	pos := node.Pos()
+	return tokens.Token{Literal: node.TokenLiteral(), File: pos.File, Row: pos.Row, Col: pos.Col}
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL, FALSE:
+		return false
+	}
+	return true
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
@@ -0,0 +1,377 @@
+package evaluator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joerdav/brev/object"
+	"github.com/joerdav/brev/parser"
+	"github.com/joerdav/brev/tokens"
+	"github.com/matryer/is"
+)
+
+func testEval(t *testing.T, input string, opts ...Option) object.Object {
+	return testEvalContext(t, context.Background(), input, opts...)
+}
+
+func testEvalContext(t *testing.T, ctx context.Context, input string, opts ...Option) object.Object {
+	t.Helper()
+	program, err := parser.ParseString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Eval(ctx, program, object.NewEnvironment(), opts...)
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{"integer", "5", "5"},
+		{"negative", "-10", "-10"},
+		{"arithmetic", "(5 + 10 * 2 + 15 / 3) * 2 + -10", "50"},
+		{"integer division", "7 / 2", "3"},
+		{"remainder", "[7 % 3, -7 % 3, 1 + 7 % 3 * 2]", "[1, -1, 3]"},
+		{"comparison", "[1 < 2, 1 > 2, 1 == 1, 1 != 1]", "[T, F, T, F]"},
+		{"booleans", "[T == T, T != F, F == (1 > 2)]", "[T, T, T]"},
+		{"bang", "[!T, !F, !!T, !5]", "[F, T, T, F]"},
+		{"strings", `"hello" + " " + "world"`, `"hello world"`},
+		{"string equality", `["a" == "a", "a" != "b"]`, "[T, T]"},
+		{"assignment", "a = 5 b = a * 2 b", "10"},
+		{"assignment value", "a = 5", "5"},
+		{"compound assignment", "a = 5 a += 2 a *= 3 a -= 1 a /= 4 a %= 3 a", "2"},
+		{"empty program", "", "null"},
+		{"array", "[1, 2 * 2, 3 + 3]", "[1, 4, 6]"},
+		{"array index", "xs = [1, 2, 3] xs[0] + xs[2]", "4"},
+		{"array assignment", "xs = [1, 2, 3] xs[1] = 5 xs[2] += 1 xs", "[1, 5, 4]"},
+		{"slices", "xs = [1, 2, 3, 4]\n[xs[1..3], xs[0..=1], xs[2..], xs[..]]", "[[2, 3], [1, 2], [3, 4], [1, 2, 3, 4]]"},
+		{"slice to inclusive end", "xs = [1, 2, 3, 4]\nn = 1\n[xs[..=n], xs[..=3]]", "[[1, 2], [1, 2, 3, 4]]"},
+		{"string index", "s = \"héllo\"\n" + `[s[1], s[1..3]]`, `["é", "él"]`},
+		{"map", `{"a": 1, 2: T, T: "x"}`, `{"a": 1, 2: T, T: "x"}`},
+		{"map shorthand", "a = 1\n{a}", `{"a": 1}`},
+		{"map index", `m1 = {"a": 1}` + "\n" + `[m1["a"], m1["b"]]`, "[1, null]"},
+		{"map assignment", `m1 = {"a": 1} m1["b"] = 2 m1["a"] += 1 m1`, `{"a": 2, "b": 2}`},
+		{"functions", "add = f(a, b) { a + b } add(1, 2 * 3)", "7"},
+		{"function value", "f(a, b: int) { a }", "f(a, b: int) { ... }"},
+		{"function body", "g = f(x) { y = x * 2\ny + 1 } g(2)", "5"},
+		{"nested calls", "add = f(a, b) { a + b } add(add(1, 1), add(2, 2))", "6"},
+		{"pipes", "add = f(a, b) { a + b } double = f(x) { x * 2 } 1 |> add(2) |> double", "6"},
+		{"pipe into expressions", "t P { g } p = P{g: f(x) { x + 1 }} fs = [f(x) { x * 2 }] 1 |> fs[0] |> p.g |> f(x) { x * 10 }", "30"},
+		{"for range", "total = 0 l n in 1..=10 { total += n } total", "55"},
+		{"for exclusive range", "total = 0 l n in 0..3 { total += n } total", "3"},
+		{"for empty range", "n = 0 l x in 3..3 { n += 1 } l x in 3..=2 { n += 1 } n", "0"},
+		{"for range to max", "n = 0 l x in 9223372036854775807..=9223372036854775807 { n += 1 } n", "1"},
+		{"for range to min", "lo = -9223372036854775807 - 1\nn = 0 l x in lo..lo { n += 1 } l x in lo..=lo { n += 1 } n", "1"},
+		{"for array", "s = \"\" l x in [\"a\", \"b\"] { s += x } s", `"ab"`},
+		{"for map", `n = 0 l k in {"a": 1, "b": 2} { n += 1 } n`, "2"},
+		{"for string", `n = 0 l c in "abc" { n += 1 } n`, "3"},
+		{"for value", "l x in [1] { x }", "null"},
+		{"range", "1..=5", "1..=5"},
+		{"records", "t P { x: int, y } p = P{x: 1, y: 2} p.x + p.y", "3"},
+		{"record value", "t P { x, y } y = 2\nP{x: 1, y}", "P{x: 1, y: 2}"},
+		{"record missing field", "t P { x, y } P{x: 1}", "P{x: 1, y: null}"},
+		{"record assignment", "t P { x } p = P{x: 1} p.x += 1 p.x", "2"},
+		{"record equality", "t P { x } [P{x: 1} == P{x: 1}, P{x: 1} == P{x: 2}]", "[T, F]"},
+		{"pattern assignment", "a, b = 1, 2 a, b = b, a\n[a, b]", "[2, 1]"},
+		{"destructuring", "[x, [y, _]] = [1, [2, 3]]\n{\"k\": z} = {\"k\": 4}\n[x, y, z]", "[1, 2, 4]"},
+		{"destructuring records", "t P { name, age } {name, age} = P{name: \"a\", age: 3} age", "3"},
+		{"match literal", `m 1 + 1 { 1 -> "one", 2 -> "two", _ -> "many" }`, `"two"`},
+		{"match negative", `m -1 { -1 -> "minus one", _ -> "other" }`, `"minus one"`},
+		{"match binding", "m 5 { n i n > 10 -> 0, n -> n * 2 }", "10"},
+		{"match array", "m [1, 2] { [a] -> a, [a, b] -> a + b }", "3"},
+		{"match map", `m {"name": "x", "age": 3} { {"name": n, "age": 4} -> 0, {age} -> age }`, "3"},
+		{"list equality", `[[1, [2]] == [1, [2]], {"a": [1]} == {"a": [1]}, [1] == [2], 1 == "1"]`, "[T, T, F, F]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(testEval(t, tt.input).Inspect(), tt.expected)
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		input, expected string
+	}{
+		{"5 + T", "type mismatch: int + bool"},
+		{"-T", "unknown operator: -bool"},
+		{"T + F", "unknown operator: bool + bool"},
+		{`"a" - "b"`, "unknown operator: str - str"},
+		{"5 + T\n5", "type mismatch: int + bool"},
+		{"foobar", "identifier not found: foobar"},
+		{"x += 1", "identifier not found: x"},
+		{"1 / 0", "division by zero"},
+		{"[1][1]", "index out of range: 1 with length 1"},
+		{"[1][-1]", "index out of range: -1 with length 1"},
+		{"[1][18446744073709551616]", "index out of range: 18446744073709551616 with length 1"},
+		{"18446744073709551616 / 0", "division by zero"},
+		{"18446744073709551616 + T", "type mismatch: int + bool"},
+		{"[1, 2][1..3]", "slice bounds out of range: 1..3 with length 2"},
+		{"1[0]", "index operator not supported: int[int]"},
+		{"{[1]: 2}", "unusable as map key: list"},
+		{"x = 1 x(2)", "not a function: int"},
+		{"g = f(a) { a } g()", "wrong number of arguments: want 1, got 0"},
+		{"l x in 5 { x }", "cannot loop over int"},
+		{"l x in (1..) { x }", "cannot loop over a range without an end"},
+		{"T..3", "range bounds must be int, got bool"},
+		{"1..=18446744073709551616", "range bound out of range: 18446744073709551616"},
+		{"n = 0\nl x in 18446744073709551610..18446744073709551612 { n += 1 }", "range bound out of range: 18446744073709551610"},
+		{"[1][0..18446744073709551616]", "range bound out of range: 18446744073709551616"},
+		{"[1][-18446744073709551616..]", "range bound out of range: -18446744073709551616"},
+		{"t P { x } P{y: 1}", "P has no field y"},
+		{"t P { x } P{x: 1}.y", "P has no field y"},
+		{"x = 1 x.y", "cannot select field y of int"},
+		{"y = 1 P{y}", "identifier not found: P"},
+		{"y = 1 P = 2 P{y}", "P is not a type"},
+		{"m 3 { 1 -> 1, 2 -> 2 }", "no match arm matched 3"},
+		{"[a, b] = [1]", "cannot assign [1] to [a, b]"},
+		{`im "lib"`, "imports are not supported when evaluating"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			result := testEval(t, tt.input)
+			err, ok := result.(*object.Error)
+			is.True(ok) // result is an error
+			is.Equal(err.Message, tt.expected)
+		})
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{"literal", "123456789012345678901234567890", "123456789012345678901234567890"},
+		{"overflow", "9223372036854775807 + 1", "9223372036854775808"},
+		{"underflow", "-9223372036854775807 - 2", "-9223372036854775809"},
+		{"multiply", "4294967296 * 4294967296", "18446744073709551616"},
+		{"negate min", "-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"divide min", "(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"multiply min", "a = -9223372036854775807 - 1\n[a * -1, -1 * a]", "[9223372036854775808, 9223372036854775808]"},
+		{"back to int", "x = 9223372036854775807 + 1 x - 1 == 9223372036854775807", "T"},
+		{"big division", "100000000000000000000 / 3", "33333333333333333333"},
+		{"comparison", "[18446744073709551616 > 1, 1 < -18446744073709551616, 18446744073709551616 == 18446744073709551616, 18446744073709551616 != 1]", "[T, F, T, T]"},
+		{"factorial", "fact = f(n) { m n { 0 -> 1, _ -> n * fact(n - 1) } } fact(25)", "15511210043330985984000000"},
+		{"map keys", "k = 18446744073709551616\n{k: 1}[18446744073709551615 + 1]", "1"},
+		{"big and small keys", `{18446744073709551616: "big", 5952119183343170477: "small"}`, `{18446744073709551616: "big", 5952119183343170477: "small"}`},
+		{"match", `m 9223372036854775807 + 1 { 9223372036854775808 -> "big", _ -> "small" }`, `"big"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(testEval(t, tt.input).Inspect(), tt.expected)
+		})
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{"top level", "x = 1\ny = -T", "error: unknown operator: -bool\n\nmain()\n\t2:5\n"},
+		{"call", "negate = f(b) {\n\t-b\n}\nnegate(T)",
+			"error: unknown operator: -bool\n\nnegate(...)\n\t2:2\nmain()\n\t4:7\n"},
+		{"nested calls", "inner = f(x) { x(1) }\nouter = f(x) {\n\tinner(x)\n}\n1 |> outer",
+			"error: not a function: int\n\ninner(...)\n\t1:17\nouter(...)\n\t3:7\nmain()\n\t5:3\n"},
+		{"anonymous", "f() { [][0] }()", "error: index out of range: 0 with length 0\n\nanonymous(...)\n\t1:9\nmain()\n\t1:14\n"},
+		{"recursion", "down = f(n) {\n\tm n { 0 -> n.x, _ -> down(n - 1) }\n}\ndown(2)",
+			"error: cannot select field x of int\n\ndown(...)\n\t2:14\ndown(...)\n\t2:27\ndown(...)\n\t2:27\nmain()\n\t4:5\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			err, ok := testEval(t, tt.input).(*object.Error)
+			is.True(ok) // result is an error
+			is.Equal(err.Trace(), tt.expected)
+		})
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	is := is.New(t)
+	path := filepath.Join(t.TempDir(), "fail.brev")
+	is.NoErr(os.WriteFile(path, []byte("half = f(n) {\n\tn / 0\n}\nhalf(4)\n"), 0o644))
+	program, err := parser.ParseFile(path)
+	is.NoErr(err)
+	result, ok := Eval(context.Background(), program, object.NewEnvironment()).(*object.Error)
+	is.True(ok) // result is an error
+	is.Equal(result.Token, tokens.Token{Type: tokens.SLASH, Literal: "/", File: path, Row: 1, Col: 3})
+	is.Equal(len(result.Stack), 1)
+	is.Equal(result.Stack[0].Function, "half")
+	is.Equal(result.Stack[0].Call.Pos().String(), path+":3:4")
+	is.Equal(result.Trace(), "error: division by zero\n\nhalf(...)\n\t"+path+":2:4\nmain()\n\t"+path+":4:5\n")
+
+	// An error raised at a literal is reported there, not at its parent.
+	program, err = parser.ParseString("a = \"a\"\nb = \"b\"")
+	is.NoErr(err)
+	result, ok = Eval(context.Background(), program, object.NewEnvironment(), WithMaxObjects(1)).(*object.Error)
+	is.True(ok) // result is an error
+	is.Equal(result.Message, "object limit exceeded: 1")
+	is.Equal(result.Token, tokens.Token{Type: tokens.STRING, Literal: "b", Raw: `"b"`, Row: 1, Col: 4})
+}
+
+func TestLimits(t *testing.T) {
+	countdown := "down = f(n) { m n { 0 -> 0, _ -> down(n - 1) } }\n"
+	tests := []struct {
+		name, input string
+		opts        []Option
+		expected    string
+	}{
+		{"steps", "l n in 0..1000 { n }", []Option{WithMaxSteps(100)}, "step limit exceeded: 100"},
+		{"depth", countdown + "down(20)", []Option{WithMaxDepth(10)}, "stack overflow: call depth exceeds 10"},
+		{"default depth", countdown + "down(100000)", nil, "stack overflow: call depth exceeds 10000"},
+		{"objects", "xs = [] l n in 0..1000 { xs = [n] }", []Option{WithMaxObjects(50)}, "object limit exceeded: 50"},
+		{"objects in strings", `s = "" l c in "abcdef" { s += c }`, []Option{WithMaxObjects(5)}, "object limit exceeded: 5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			err, ok := testEval(t, tt.input, tt.opts...).(*object.Error)
+			is.True(ok) // result is an error
+			is.Equal(err.Message, tt.expected)
+		})
+	}
+}
+
+func TestWithinLimits(t *testing.T) {
+	is := is.New(t)
+	input := "down = f(n) { m n { 0 -> 0, _ -> down(n - 1) } } down(10)"
+	result := testEval(t, input, WithMaxSteps(1000), WithMaxDepth(11), WithMaxObjects(100))
+	is.Equal(result.Inspect(), "0")
+}
+
+func TestCyclicValues(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{"equal self", "xs = [0]\nxs[0] = xs\nxs == xs", "T"},
+		{"equal other cycle", "xs = [0]\nxs[0] = xs\nys = [0]\nys[0] = ys\nxs == ys", "T"},
+		{"not equal", "xs = [0, 1]\nxs[0] = xs\nys = [0, 2]\nys[0] = ys\nxs != ys", "T"},
+		{"map", `d = {}` + "\n" + `d["d"] = d` + "\n" + `[d == d, d]`, `[T, {"d": {...}}]`},
+		{"inspect", "xs = [0]\nxs[0] = xs\nxs", "[[...]]"},
+		{"record", "t P { p }\np = P{}\np.p = p\n[p == p, p]", "[T, P{p: P{...}}]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(testEval(t, tt.input).Inspect(), tt.expected)
+		})
+	}
+}
+
+func TestStackOverflowTrace(t *testing.T) {
+	is := is.New(t)
+	err, ok := testEval(t, "down = f(n) { down(n + 1) }\ndown(0)", WithMaxDepth(200)).(*object.Error)
+	is.True(ok) // result is an error
+	is.Equal(len(err.Stack), 200)
+	trace := err.Trace()
+	is.Equal(strings.Count(trace, "down(...)"), 100)
+	is.True(strings.Contains(trace, "\n...100 frames elided...\ndown(...)\n"))
+	is.True(strings.HasSuffix(trace, "down(...)\n\t1:19\nmain()\n\t2:5\n"))
+}
+
+func TestCancellation(t *testing.T) {
+	t.Run("cancelled", func(t *testing.T) {
+		is := is.New(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err, ok := testEvalContext(t, ctx, "1 + 1").(*object.Error)
+		is.True(ok) // result is an error
+		is.Equal(err.Message, "context canceled")
+	})
+	t.Run("tight loop", func(t *testing.T) {
+		is := is.New(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err, ok := testEvalContext(t, ctx, "l n in 0..9223372036854775807 {}").(*object.Error)
+		is.True(ok) // result is an error
+		is.Equal(err.Message, "context deadline exceeded")
+		is.True(time.Since(start) < time.Second)
+	})
+}
+
+func TestEvalKeepsEnvironment(t *testing.T) {
+	is := is.New(t)
+	env := object.NewEnvironment()
+	for _, input := range []string{"x = 2", "double = f(n) { n * 2 }", "y = double(x)"} {
+		program, err := parser.ParseString(input)
+		is.NoErr(err)
+		Eval(context.Background(), program, env)
+	}
+	y, ok := env.Get("y")
+	is.True(ok)
+	is.Equal(y.Inspect(), "4")
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{"capture", "mk = f(n) { f(x) { x + n } } add2 = mk(2) add2(3)", "5"},
+		{"currying", "add = f(a) { f(b) { f(c) { a + b + c } } } add(1)(2)(3)", "6"},
+		{"partial application", "add = f(a) { f(b) { a + b } } inc = add(1) dec = add(-1)\n[inc(5), dec(5)]", "[6, 4]"},
+		{"counter", `counter = f() {
+	n = 0
+	f() {
+		n += 1
+		n
+	}
+}
+c1 = counter()
+c2 = counter()
+c1() c1() c2()
+[c1(), c2()]`, "[3, 2]"},
+		{"recursion", "fact = f(n) { m n { 0 -> 1, _ -> n * fact(n - 1) } } fact(10)", "3628800"},
+		{"local recursion", `fib = f(n) {
+	go = f(a, b, k) { m k { 0 -> a, _ -> go(b, a + b, k - 1) } }
+	go(0, 1, n)
+}
+fib(30)`, "832040"},
+		{"mutual recursion", `even = f(n) { m n { 0 -> T, _ -> odd(n - 1) } }
+odd = f(n) { m n { 0 -> F, _ -> even(n - 1) } }
+[even(10), odd(7), even(3)]`, "[T, T, F]"},
+		{"closures see later assignments", "n = 1 get = f() { n } n = 2 get()", "2"},
+		{"assignment rebinds outer", "total = 0 add = f(x) { total += x } add(2) add(3) total", "5"},
+		{"assignment creates local", "g = f() { local = 1 local } g()\nm1 = {\"has\": 1}", `{"has": 1}`},
+		{"parameters shadow", "x = 1 g = f(x) { x = x * 10 x }\n[g(2), x]", "[20, 1]"},
+		{"loop closures", `fs = {}
+l k in 0..3 { fs[k] = f() { k * 10 } }
+[fs[0](), fs[2]()]`, "[0, 20]"},
+		{"loop rebinds outer", "last = 0 l x in [1, 2, 3] { last = x } last", "3"},
+		{"match bindings are local", "n = 1 m 5 { n -> n } n", "1"},
+		{"pattern assignment rebinds", "a = 0 g = f() { a, b = 1, 2 b } g() a", "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(testEval(t, tt.input).Inspect(), tt.expected)
+		})
+	}
+}
+
+func TestScopeErrors(t *testing.T) {
+	tests := []struct {
+		input, expected string
+	}{
+		{"g = f() { local = 1 } g() local", "identifier not found: local"},
+		{"l x in [1] { y = x } y", "identifier not found: y"},
+		{"l x in [1] { x } x", "identifier not found: x"},
+		{"m 1 { n -> n } n", "identifier not found: n"},
+		{"g = f(a) { a } g(1) a", "identifier not found: a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			err, ok := testEval(t, tt.input).(*object.Error)
+			is.True(ok) // result is an error
+			is.Equal(err.Message, tt.expected)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/joerdav/brev/printer"
+)
+
+const fmtUsage = `usage: brev fmt [-w] [-l] [-d] [path ...]
+
+Fmt prints Brev source in canonical form. With no paths it formats standard
+input, directories are searched for .brev files.
+`
+
+// runFmt implements the fmt command.
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, fmtUsage)
+		flags.PrintDefaults()
+	}
+	f := &formatter{stdout: stdout}
+	flags.BoolVar(&f.write, "w", false, "write the result to the source file instead of stdout")
+	flags.BoolVar(&f.list, "l", false, "list files whose formatting differs")
+	flags.BoolVar(&f.diff, "d", false, "display diffs of the changes instead of printing the result")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		if f.write {
+			return errors.New("cannot use -w with standard input")
+		}
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		return f.format("<standard input>", src)
+	}
+	for _, path := range flags.Args() {
+		err := filepath.WalkDir(path, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || name != path && filepath.Ext(name) != ".brev" {
+				return nil
+			}
+			src, err := os.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			return f.format(name, src)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type formatter struct {
+	write, list, diff bool
+	stdout            io.Writer
+}
+
+// format formats the source of the named file and reports it as the flags
+// ask, printing the result if no flag is set.
+func (f *formatter) format(name string, src []byte) error {
+	res, err := printer.Source(src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	changed := !bytes.Equal(src, res)
+	if f.list && changed {
+		fmt.Fprintln(f.stdout, name)
+	}
+	if f.write && changed {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, res, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if f.diff && changed {
+		d, err := diff(name, src, res)
+		if err != nil {
+			return err
+		}
+		if _, err := f.stdout.Write(d); err != nil {
+			return err
+		}
+	}
+	if !f.list && !f.write && !f.diff {
+		_, err = f.stdout.Write(res)
+	}
+	return err
+}
+
+// diff returns the unified diff from a to b using the system diff command.
+func diff(name string, a, b []byte) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "brevfmt")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	fa, fb := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	if err := os.WriteFile(fa, a, 0o600); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(fb, b, 0o600); err != nil {
+		return nil, err
+	}
+	out, err := exec.Command("diff", "-u", "-L", name+".orig", "-L", name, fa, fb).Output()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 1 {
+		// diff exits with 1 when the files differ.
+		return out, nil
+	}
+	return out, err
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestFmtStdin(t *testing.T) {
+	is := is.New(t)
+	var out bytes.Buffer
+	err := runFmt(nil, strings.NewReader("a=1+2"), &out, &out)
+	is.NoErr(err)
+	is.Equal("a = 1 + 2\n", out.String())
+}
+
+func TestFmtListAndWrite(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	messy := filepath.Join(dir, "messy.brev")
+	tidy := filepath.Join(dir, "tidy.brev")
+	is.NoErr(os.WriteFile(messy, []byte("a=1+2"), 0o644))
+	is.NoErr(os.WriteFile(tidy, []byte("a = 1 + 2\n"), 0o644))
+	is.NoErr(os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("a=1"), 0o644))
+
+	var out bytes.Buffer
+	is.NoErr(runFmt([]string{"-l", dir}, nil, &out, &out))
+	is.Equal(messy+"\n", out.String())
+
+	out.Reset()
+	is.NoErr(runFmt([]string{"-w", messy}, nil, &out, &out))
+	is.Equal("", out.String())
+	src, err := os.ReadFile(messy)
+	is.NoErr(err)
+	is.Equal("a = 1 + 2\n", string(src))
+}
+
+func TestFmtDiff(t *testing.T) {
+	if _, err := exec.LookPath("diff"); err != nil {
+		t.Skip("diff not installed")
+	}
+	is := is.New(t)
+	name := filepath.Join(t.TempDir(), "prog.brev")
+	is.NoErr(os.WriteFile(name, []byte("a=1\n"), 0o644))
+	var out bytes.Buffer
+	is.NoErr(runFmt([]string{"-d", name}, nil, &out, &out))
+	is.True(strings.Contains(out.String(), "--- "+name+".orig\n+++ "+name+"\n"))
+	is.True(strings.Contains(out.String(), "-a=1\n+a = 1\n"))
+}
+
+func TestFmtDiffAndWrite(t *testing.T) {
+	if _, err := exec.LookPath("diff"); err != nil {
+		t.Skip("diff not installed")
+	}
+	is := is.New(t)
+	name := filepath.Join(t.TempDir(), "prog.brev")
+	is.NoErr(os.WriteFile(name, []byte("a=1\n"), 0o644))
+	var out bytes.Buffer
+	is.NoErr(runFmt([]string{"-d", "-w", name}, nil, &out, &out))
+	is.True(strings.Contains(out.String(), "-a=1\n+a = 1\n"))
+	src, err := os.ReadFile(name)
+	is.NoErr(err)
+	is.Equal("a = 1\n", string(src))
+}
+
+func TestFmtErrors(t *testing.T) {
+	is := is.New(t)
+	var out bytes.Buffer
+	err := runFmt(nil, strings.NewReader("a = "), &out, &out)
+	is.True(err != nil)
+	is.True(strings.HasPrefix(err.Error(), "<standard input>: "))
+
+	err = runFmt([]string{"-w"}, strings.NewReader("a = 1"), &out, &out)
+	is.Equal(err.Error(), "cannot use -w with standard input")
+}
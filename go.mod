@@ -4,4 +4,4 @@ go 1.17
 
 require github.com/google/go-cmp v0.5.9
 
-require github.com/matryer/is v1.4.0 // indirect
+require github.com/matryer/is v1.4.0
@@ -11,6 +11,7 @@ type Lexer struct {
 	reader        *bufio.Reader
 	current, peek *rune
 	row, col      int
+	file          string
 }
 
 func NewLexer(reader io.Reader) Lexer {
@@ -23,6 +24,18 @@ func NewLexer(reader io.Reader) Lexer {
 	return t
 }
 
+// NewFileLexer returns a Lexer whose tokens record filename as their File.
+func NewFileLexer(filename string, reader io.Reader) Lexer {
+	t := NewLexer(reader)
+	t.file = filename
+	return t
+}
+
+// Filename returns the name given to NewFileLexer, or "" if there isn't one.
+func (t *Lexer) Filename() string {
+	return t.file
+}
+
 var singleRuneTokens = map[rune]tokens.TokenType{
 	'=': tokens.ASSIGN,
 	'+': tokens.ADD,
@@ -35,11 +48,28 @@ var singleRuneTokens = map[rune]tokens.TokenType{
 	'(': tokens.LBRK,
 	')': tokens.RBRK,
 	',': tokens.COMMA,
+	'[': tokens.LSQB,
+	']': tokens.RSQB,
+	':': tokens.COLON,
+	'?': tokens.QUESTION,
+	'.': tokens.DOT,
 	'!': tokens.BANG,
 	'<': tokens.LT,
 	'>': tokens.GT,
 }
 
+var doubleRuneTokens = map[[2]rune]tokens.TokenType{
+	{'!', '='}: tokens.NE,
+	{'=', '='}: tokens.EQ,
+	{'-', '>'}: tokens.ARROW,
+	{'|', '>'}: tokens.PIPE,
+	{'+', '='}: tokens.ADD_ASSIGN,
+	{'-', '='}: tokens.SUB_ASSIGN,
+	{'*', '='}: tokens.ASTERISK_ASSIGN,
+	{'/', '='}: tokens.SLASH_ASSIGN,
+	{'%', '='}: tokens.PERCENT_ASSIGN,
+}
+
 var keywords = map[string]tokens.TokenType{
 	"f":  tokens.FUNCTION,
 	"i":  tokens.IF,
@@ -47,6 +77,11 @@ var keywords = map[string]tokens.TokenType{
 	"ei": tokens.ELIF,
 	"T":  tokens.TRUE,
 	"F":  tokens.FALSE,
+	"m":  tokens.MATCH,
+	"l":  tokens.LOOP,
+	"in": tokens.IN,
+	"t":  tokens.TYPE,
+	"im": tokens.IMPORT,
 }
 
 func (t *Lexer) NextToken() tokens.Token {
@@ -58,16 +93,25 @@ func (t *Lexer) NextToken() tokens.Token {
 	switch {
 	case validIdentFirstChar(*t.current):
 		return t.parseIdent()
-	case t.isCurrent('!') && t.isPeek('='):
-		to := t.token(tokens.NE, "!=")
+	case t.isCurrent('/') && t.isPeek('/'):
+		return t.parseComment()
+	case t.isCurrent('.') && t.isPeek('.'):
+		to := t.token(tokens.RANGE, "..")
 		t.Advance()
 		t.Advance()
+		if t.isCurrent('=') {
+			to.Type, to.Literal = tokens.RANGE_INCL, "..="
+			t.Advance()
+		}
 		return to
-	case t.isCurrent('=') && t.isPeek('='):
-		to := t.token(tokens.EQ, "==")
+	case t.peek != nil && doubleRuneTokens[[2]rune{*t.current, *t.peek}] != "":
+		lit := string([]rune{*t.current, *t.peek})
+		to := t.token(doubleRuneTokens[[2]rune{*t.current, *t.peek}], lit)
 		t.Advance()
 		t.Advance()
 		return to
+	case t.isCurrent('"'):
+		return t.parseString()
 	case single:
 		to := t.currentAsToken(ty)
 		t.Advance()
@@ -82,11 +126,11 @@ func (t *Lexer) NextToken() tokens.Token {
 }
 
 func (t *Lexer) token(tt tokens.TokenType, literal string) tokens.Token {
-	return tokens.Token{Type: tt, Literal: literal, Col: t.col, Row: t.row}
+	return tokens.Token{Type: tt, Literal: literal, Col: t.col, Row: t.row, File: t.file}
 }
 
 func (t *Lexer) currentAsToken(tt tokens.TokenType) tokens.Token {
-	return tokens.Token{Type: tt, Literal: string(*t.current), Col: t.col, Row: t.row}
+	return tokens.Token{Type: tt, Literal: string(*t.current), Col: t.col, Row: t.row, File: t.file}
 }
 
 func (t *Lexer) Advance() {
@@ -125,6 +169,57 @@ func (t *Lexer) parseNumber() tokens.Token {
 	return to
 }
 
+// parseComment reads a `//` comment up to the end of the line, the literal
+// includes the slashes.
+func (t *Lexer) parseComment() tokens.Token {
+	to := t.token(tokens.COMMENT, "")
+	for t.current != nil && !t.isCurrent('\n') && !t.isCurrent('\r') {
+		to.Literal += string(*t.current)
+		t.Advance()
+	}
+	return to
+}
+
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'"':  '"',
+	'\\': '\\',
+}
+
+// parseString reads a double quoted string, the token literal holds the
+// unescaped contents without the quotes and Raw holds the source text. A
+// string still open at the end of the input is ILLEGAL, with the opening
+// quote kept in the literal.
+func (t *Lexer) parseString() tokens.Token {
+	to := t.token(tokens.STRING, "")
+	raw := []rune{'"'}
+	t.Advance()
+	for !t.isCurrent('"') {
+		if t.current == nil {
+			to.Type = tokens.ILLEGAL
+			to.Literal = `"` + to.Literal
+			to.Raw = string(raw)
+			return to
+		}
+		r := *t.current
+		raw = append(raw, r)
+		if r == '\\' && t.peek != nil {
+			if e, ok := escapes[*t.peek]; ok {
+				raw = append(raw, *t.peek)
+				r = e
+				t.Advance()
+			}
+		}
+		to.Literal += string(r)
+		t.Advance()
+	}
+	t.Advance()
+	to.Raw = string(append(raw, '"'))
+	return to
+}
+
 func (t *Lexer) isCurrent(r rune) bool {
 	return t.current != nil && *t.current == r
 }
@@ -229,3 +229,203 @@ i 5 < 10 {
 		}
 	}
 }
+
+func TestMatchTokens(t *testing.T) {
+	input := `m v {
+	"a\"b" -> [x, _],
+	{"k": y} i y > 0 -> y
+}`
+	r := strings.NewReader(input)
+	l := NewLexer(r)
+
+	tests := []tokens.Token{
+		{Type: tokens.MATCH, Literal: "m", Col: 0, Row: 0},
+		{Type: tokens.IDENT, Literal: "v", Col: 2, Row: 0},
+		{Type: tokens.LBRC, Literal: "{", Col: 4, Row: 0},
+		{Type: tokens.STRING, Literal: `a"b`, Col: 1, Row: 1},
+		{Type: tokens.ARROW, Literal: "->", Col: 8, Row: 1},
+		{Type: tokens.LSQB, Literal: "[", Col: 11, Row: 1},
+		{Type: tokens.IDENT, Literal: "x", Col: 12, Row: 1},
+		{Type: tokens.COMMA, Literal: ",", Col: 13, Row: 1},
+		{Type: tokens.IDENT, Literal: "_", Col: 15, Row: 1},
+		{Type: tokens.RSQB, Literal: "]", Col: 16, Row: 1},
+		{Type: tokens.COMMA, Literal: ",", Col: 17, Row: 1},
+		{Type: tokens.LBRC, Literal: "{", Col: 1, Row: 2},
+		{Type: tokens.STRING, Literal: "k", Col: 2, Row: 2},
+		{Type: tokens.COLON, Literal: ":", Col: 5, Row: 2},
+		{Type: tokens.IDENT, Literal: "y", Col: 7, Row: 2},
+		{Type: tokens.RBRC, Literal: "}", Col: 8, Row: 2},
+		{Type: tokens.IF, Literal: "i", Col: 10, Row: 2},
+		{Type: tokens.IDENT, Literal: "y", Col: 12, Row: 2},
+		{Type: tokens.GT, Literal: ">", Col: 14, Row: 2},
+		{Type: tokens.NUMBER, Literal: "0", Col: 16, Row: 2},
+		{Type: tokens.ARROW, Literal: "->", Col: 18, Row: 2},
+		{Type: tokens.IDENT, Literal: "y", Col: 21, Row: 2},
+		{Type: tokens.RBRC, Literal: "}", Col: 0, Row: 3},
+		{Type: tokens.EOF, Literal: "", Col: 1, Row: 3},
+	}
+
+	for _, tok := range tests {
+		c := l.NextToken()
+		if c.Type != tok.Type {
+			t.Fatalf("c.Type for %s was not the expected value. want=%#v got=%#v", tok.Literal, tok, c)
+		}
+		if c.Literal != tok.Literal {
+			t.Fatalf("c.Literal was not the expected value. want=%#v got=%#v", tok, c)
+		}
+		if c.Col != tok.Col {
+			t.Fatalf("c.Col for %s was not the expected value. want=%#v got=%#v", tok.Literal, tok, c)
+		}
+		if c.Row != tok.Row {
+			t.Fatalf("c.Row for %s was not the expected value. want=%#v got=%#v", tok.Literal, tok, c)
+		}
+	}
+}
+
+func TestCompoundAssignTokens(t *testing.T) {
+	input := "a += 1 -= *= /= %= - ="
+	l := NewLexer(strings.NewReader(input))
+
+	tests := []tokens.Token{
+		{Type: tokens.IDENT, Literal: "a", Col: 0},
+		{Type: tokens.ADD_ASSIGN, Literal: "+=", Col: 2},
+		{Type: tokens.NUMBER, Literal: "1", Col: 5},
+		{Type: tokens.SUB_ASSIGN, Literal: "-=", Col: 7},
+		{Type: tokens.ASTERISK_ASSIGN, Literal: "*=", Col: 10},
+		{Type: tokens.SLASH_ASSIGN, Literal: "/=", Col: 13},
+		{Type: tokens.PERCENT_ASSIGN, Literal: "%=", Col: 16},
+		{Type: tokens.SUB, Literal: "-", Col: 19},
+		{Type: tokens.ASSIGN, Literal: "=", Col: 21},
+		{Type: tokens.EOF, Literal: "", Col: 22},
+	}
+
+	for _, tok := range tests {
+		c := l.NextToken()
+		if c.Type != tok.Type {
+			t.Fatalf("c.Type for %s was not the expected value. want=%#v got=%#v", tok.Literal, tok, c)
+		}
+		if c.Literal != tok.Literal {
+			t.Fatalf("c.Literal was not the expected value. want=%#v got=%#v", tok, c)
+		}
+		if c.Col != tok.Col {
+			t.Fatalf("c.Col for %s was not the expected value. want=%#v got=%#v", tok.Literal, tok, c)
+		}
+	}
+}
+
+func TestRangeAndLoopTokens(t *testing.T) {
+	input := "l x in 1..10 { xs[..=n] }"
+	l := NewLexer(strings.NewReader(input))
+
+	tests := []tokens.Token{
+		{Type: tokens.LOOP, Literal: "l", Col: 0},
+		{Type: tokens.IDENT, Literal: "x", Col: 2},
+		{Type: tokens.IN, Literal: "in", Col: 4},
+		{Type: tokens.NUMBER, Literal: "1", Col: 7},
+		{Type: tokens.RANGE, Literal: "..", Col: 8},
+		{Type: tokens.NUMBER, Literal: "10", Col: 10},
+		{Type: tokens.LBRC, Literal: "{", Col: 13},
+		{Type: tokens.IDENT, Literal: "xs", Col: 15},
+		{Type: tokens.LSQB, Literal: "[", Col: 17},
+		{Type: tokens.RANGE_INCL, Literal: "..=", Col: 18},
+		{Type: tokens.IDENT, Literal: "n", Col: 21},
+		{Type: tokens.RSQB, Literal: "]", Col: 22},
+		{Type: tokens.RBRC, Literal: "}", Col: 24},
+		{Type: tokens.EOF, Literal: "", Col: 25},
+	}
+
+	for _, tok := range tests {
+		c := l.NextToken()
+		if c.Type != tok.Type {
+			t.Fatalf("c.Type for %s was not the expected value. want=%#v got=%#v", tok.Literal, tok, c)
+		}
+		if c.Literal != tok.Literal {
+			t.Fatalf("c.Literal was not the expected value. want=%#v got=%#v", tok, c)
+		}
+		if c.Col != tok.Col {
+			t.Fatalf("c.Col for %s was not the expected value. want=%#v got=%#v", tok.Literal, tok, c)
+		}
+	}
+}
+
+func TestRecordTokens(t *testing.T) {
+	input := "t P { x } p.x xs[1..2]"
+	l := NewLexer(strings.NewReader(input))
+
+	tests := []tokens.Token{
+		{Type: tokens.TYPE, Literal: "t", Col: 0},
+		{Type: tokens.IDENT, Literal: "P", Col: 2},
+		{Type: tokens.LBRC, Literal: "{", Col: 4},
+		{Type: tokens.IDENT, Literal: "x", Col: 6},
+		{Type: tokens.RBRC, Literal: "}", Col: 8},
+		{Type: tokens.IDENT, Literal: "p", Col: 10},
+		{Type: tokens.DOT, Literal: ".", Col: 11},
+		{Type: tokens.IDENT, Literal: "x", Col: 12},
+		{Type: tokens.IDENT, Literal: "xs", Col: 14},
+		{Type: tokens.LSQB, Literal: "[", Col: 16},
+		{Type: tokens.NUMBER, Literal: "1", Col: 17},
+		{Type: tokens.RANGE, Literal: "..", Col: 18},
+		{Type: tokens.NUMBER, Literal: "2", Col: 20},
+		{Type: tokens.RSQB, Literal: "]", Col: 21},
+		{Type: tokens.EOF, Literal: "", Col: 22},
+	}
+
+	for _, tok := range tests {
+		c := l.NextToken()
+		if c.Type != tok.Type {
+			t.Fatalf("c.Type for %s was not the expected value. want=%#v got=%#v", tok.Literal, tok, c)
+		}
+		if c.Literal != tok.Literal {
+			t.Fatalf("c.Literal was not the expected value. want=%#v got=%#v", tok, c)
+		}
+		if c.Col != tok.Col {
+			t.Fatalf("c.Col for %s was not the expected value. want=%#v got=%#v", tok.Literal, tok, c)
+		}
+	}
+}
+
+func TestCommentTokens(t *testing.T) {
+	input := "a // note\n// own line\nb / c"
+	l := NewFileLexer("prog.brev", strings.NewReader(input))
+
+	tests := []tokens.Token{
+		{Type: tokens.IDENT, Literal: "a", Col: 0, Row: 0},
+		{Type: tokens.COMMENT, Literal: "// note", Col: 2, Row: 0},
+		{Type: tokens.COMMENT, Literal: "// own line", Col: 0, Row: 1},
+		{Type: tokens.IDENT, Literal: "b", Col: 0, Row: 2},
+		{Type: tokens.SLASH, Literal: "/", Col: 2, Row: 2},
+		{Type: tokens.IDENT, Literal: "c", Col: 4, Row: 2},
+		{Type: tokens.EOF, Literal: "", Col: 5, Row: 2},
+	}
+
+	for _, tok := range tests {
+		c := l.NextToken()
+		tok.File = "prog.brev"
+		if c != tok {
+			t.Fatalf("token was not the expected value. want=%#v got=%#v", tok, c)
+		}
+	}
+}
+
+func TestStringRawTokens(t *testing.T) {
+	input := "\"a\\tb\" \"c\td\n\\q\" x"
+	l := NewLexer(strings.NewReader(input))
+
+	tests := []tokens.Token{
+		{Type: tokens.STRING, Literal: "a\tb", Raw: `"a\tb"`, Col: 0, Row: 0},
+		{Type: tokens.STRING, Literal: "c\td\n\\q", Raw: "\"c\td\n\\q\"", Col: 7, Row: 0},
+		{Type: tokens.IDENT, Literal: "x", Col: 4, Row: 1},
+		{Type: tokens.EOF, Literal: "", Col: 5, Row: 1},
+	}
+
+	for _, tok := range tests {
+		c := l.NextToken()
+		if c != tok {
+			t.Fatalf("token was not the expected value. want=%#v got=%#v", tok, c)
+		}
+	}
+	end := tests[1].End()
+	if end.Row != 1 || end.Col != 3 {
+		t.Fatalf("end of multi-line string was not the expected value. want=1:3 got=%s", end)
+	}
+}
@@ -8,7 +8,16 @@ import (
 	"github.com/joerdav/brev/repl"
 )
 
-func run() error {
+func run(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "fmt":
+			return runFmt(args[1:], os.Stdin, os.Stdout, os.Stderr)
+		case "ast":
+			return runAST(args[1:], os.Stdin, os.Stdout, os.Stderr)
+		}
+		return fmt.Errorf("unknown command %q, expected fmt, ast or no command to start the repl", args[0])
+	}
 	version := "devel"
 	in, ok := debug.ReadBuildInfo()
 	if ok && in.Main.Version != "" {
@@ -20,7 +29,7 @@ func run() error {
 }
 
 func main() {
-	if err := run(); err != nil {
+	if err := run(os.Args[1:]); err != nil {
 		fmt.Println(err.Error())
 		os.Exit(1)
 	}
@@ -0,0 +1,343 @@
+// Package object defines the values Brev programs work with when they run.
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/joerdav/brev/ast"
+	"github.com/joerdav/brev/tokens"
+)
+
+type ObjectType string
+
+// The types of objects, named as they are in Brev where the type can be
+// written.
+const (
+	INTEGER_OBJ     ObjectType = "int"
+	BOOLEAN_OBJ     ObjectType = "bool"
+	STRING_OBJ      ObjectType = "str"
+	NULL_OBJ        ObjectType = "null"
+	ARRAY_OBJ       ObjectType = "list"
+	MAP_OBJ         ObjectType = "map"
+	RANGE_OBJ       ObjectType = "range"
+	FUNCTION_OBJ    ObjectType = "function"
+	RECORD_OBJ      ObjectType = "record"
+	RECORD_TYPE_OBJ ObjectType = "type"
+	ERROR_OBJ       ObjectType = "error"
+)
+
+// Object is a value of a running Brev program.
+type Object interface {
+	Type() ObjectType
+	// Inspect returns the object as it would be written in Brev, where it
+	// can be.
+	Inspect() string
+}
+
+// Hashable is an object that can be the key of a map.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+// HashKey identifies the key of a map entry, equal keys have equal hash
+// keys.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+	// Big is the value of a BigInteger key, which doesn't fit in Value, so
+	// that it can't be mistaken for an Integer key.
+	Big string
+}
+
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Inspect() string  { return strconv.FormatInt(i.Value, 10) }
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// BigInteger is an int too large for an Integer. Arithmetic promotes to it
+// when a result overflows an int64, and returns an Integer again when the
+// result fits.
+type BigInteger struct {
+	Value *big.Int
+}
+
+// NewInteger returns v as an Integer if it fits in an int64, otherwise as a
+// BigInteger.
+func NewInteger(v *big.Int) Object {
+	if v.IsInt64() {
+		return &Integer{Value: v.Int64()}
+	}
+	return &BigInteger{Value: v}
+}
+
+func (bi *BigInteger) Type() ObjectType { return INTEGER_OBJ }
+func (bi *BigInteger) Inspect() string  { return bi.Value.String() }
+func (bi *BigInteger) HashKey() HashKey {
+	return HashKey{Type: bi.Type(), Big: bi.Value.String()}
+}
+
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
+func (b *Boolean) Inspect() string {
+	if b.Value {
+		return "T"
+	}
+	return "F"
+}
+func (b *Boolean) HashKey() HashKey {
+	if b.Value {
+		return HashKey{Type: b.Type(), Value: 1}
+	}
+	return HashKey{Type: b.Type()}
+}
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return strconv.Quote(s.Value) }
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+// Null is the absence of a value, such as the result of a loop or a missing
+// map key.
+type Null struct{}
+
+func (n *Null) Type() ObjectType { return NULL_OBJ }
+func (n *Null) Inspect() string  { return "null" }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string  { return inspect(a, map[Object]bool{}) }
+
+// MapPair is an entry of a map.
+type MapPair struct {
+	Key   Object
+	Value Object
+}
+
+// Map holds its entries in the order their keys were first added.
+type Map struct {
+	Pairs map[HashKey]MapPair
+	Keys  []HashKey
+}
+
+// NewMap returns an empty map.
+func NewMap() *Map {
+	return &Map{Pairs: map[HashKey]MapPair{}}
+}
+
+// Get returns the value of key, and whether it is in the map.
+func (m *Map) Get(key Hashable) (Object, bool) {
+	pair, ok := m.Pairs[key.HashKey()]
+	return pair.Value, ok
+}
+
+// Set sets the value of key.
+func (m *Map) Set(key Hashable, value Object) {
+	hash := key.HashKey()
+	if _, ok := m.Pairs[hash]; !ok {
+		m.Keys = append(m.Keys, hash)
+	}
+	m.Pairs[hash] = MapPair{Key: key, Value: value}
+}
+
+func (m *Map) Type() ObjectType { return MAP_OBJ }
+func (m *Map) Inspect() string  { return inspect(m, map[Object]bool{}) }
+
+// Range is `Low..High`, or `Low..=High` when Inclusive. A range without an
+// end, `Low..`, can only be used to slice.
+type Range struct {
+	Low, High int64
+	HasHigh   bool
+	Inclusive bool
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	var out bytes.Buffer
+	out.WriteString(strconv.FormatInt(r.Low, 10))
+	out.WriteString("..")
+	if r.Inclusive {
+		out.WriteString("=")
+	}
+	if r.HasHigh {
+		out.WriteString(strconv.FormatInt(r.High, 10))
+	}
+	return out.String()
+}
+
+// Function is a function literal and the environment it was defined in.
+type Function struct {
+	Parameters []*ast.Parameter
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	params := make([]string, len(f.Parameters))
+	for i, p := range f.Parameters {
+		params[i] = p.String()
+	}
+	return "f(" + strings.Join(params, ", ") + ") { ... }"
+}
+
+// RecordType is a type declared with `t`, it is called with a record literal
+// to make records.
+type RecordType struct {
+	Name   string
+	Fields []string
+}
+
+func (rt *RecordType) Type() ObjectType { return RECORD_TYPE_OBJ }
+func (rt *RecordType) Inspect() string {
+	return "t " + rt.Name + " { " + strings.Join(rt.Fields, ", ") + " }"
+}
+
+// HasField reports whether records of the type have the field.
+func (rt *RecordType) HasField(name string) bool {
+	for _, f := range rt.Fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+type Record struct {
+	RecordType *RecordType
+	Fields     map[string]Object
+}
+
+func (r *Record) Type() ObjectType { return RECORD_OBJ }
+func (r *Record) Inspect() string  { return inspect(r, map[Object]bool{}) }
+
+// inspect returns the Inspect of obj. A list, map or record that contains
+// itself is written as `[...]`, `{...}` or `Name{...}` where it appears
+// inside itself, seen holds the objects obj is inside of.
+func inspect(obj Object, seen map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *Array, *Map, *Record:
+		if seen[obj] {
+			switch obj := obj.(type) {
+			case *Array:
+				return "[...]"
+			case *Record:
+				return obj.RecordType.Name + "{...}"
+			}
+			return "{...}"
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+	}
+	switch obj := obj.(type) {
+	case *Array:
+		elems := make([]string, len(obj.Elements))
+		for i, e := range obj.Elements {
+			elems[i] = inspect(e, seen)
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case *Map:
+		pairs := make([]string, len(obj.Keys))
+		for i, k := range obj.Keys {
+			pair := obj.Pairs[k]
+			pairs[i] = pair.Key.Inspect() + ": " + inspect(pair.Value, seen)
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	case *Record:
+		fields := make([]string, len(obj.RecordType.Fields))
+		for i, name := range obj.RecordType.Fields {
+			fields[i] = name + ": " + inspect(obj.Fields[name], seen)
+		}
+		return obj.RecordType.Name + "{" + strings.Join(fields, ", ") + "}"
+	}
+	return obj.Inspect()
+}
+
+// Error is a failure of the running program, it stops evaluation.
+type Error struct {
+	Message string
+	// Token is the token of the node that failed, it has no Type until the
+	// error has been returned by the node.
+	Token tokens.Token
+	// Stack holds the calls the error was returned through, innermost
+	// first.
+	Stack []Frame
+}
+
+// traceFrames is the number of calls printed at each end of a deep stack.
+const traceFrames = 50
+
+// Frame is a call of a function, named as it was called, and the token of
+// the call.
+type Frame struct {
+	Function string
+	Call     tokens.Token
+}
+
+func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string  { return "error: " + e.Message }
+
+// Trace returns the error and where it happened in each function of the
+// stack, in the style of a Go panic:
+//
+//	error: unknown operator: -bool
+//
+//	negate(...)
+//		main.brev:2:13
+//	main()
+//		main.brev:4:1
+//
+// Lines and columns count from 1, as editors count them. Like Go, only the
+// innermost and outermost calls of a deep stack are printed.
+func (e *Error) Trace() string {
+	var out bytes.Buffer
+	out.WriteString(e.Inspect() + "\n\n")
+	at := e.Token
+	for i, f := range e.Stack {
+		switch {
+		case len(e.Stack) <= 2*traceFrames || i < traceFrames || i >= len(e.Stack)-traceFrames:
+			fmt.Fprintf(&out, "%s(...)\n\t%s\n", f.Function, location(at))
+		case i == traceFrames:
+			fmt.Fprintf(&out, "...%d frames elided...\n", len(e.Stack)-2*traceFrames)
+		}
+		at = f.Call
+	}
+	fmt.Fprintf(&out, "main()\n\t%s\n", location(at))
+	return out.String()
+}
+
+// location returns where t is as `file:line:col`, counting from 1.
+func location(t tokens.Token) string {
+	pos := t.Pos()
+	pos.Row++
+	pos.Col++
+	return pos.String()
+}
+
+// Errorf returns an error with the formatted message.
+func Errorf(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
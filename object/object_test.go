@@ -0,0 +1,119 @@
+package object
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/joerdav/brev/tokens"
+	"github.com/matryer/is"
+)
+
+func TestHashKey(t *testing.T) {
+	is := is.New(t)
+	is.Equal((&String{Value: "a"}).HashKey(), (&String{Value: "a"}).HashKey())
+	is.True((&String{Value: "a"}).HashKey() != (&String{Value: "b"}).HashKey())
+	is.Equal((&Integer{Value: 1}).HashKey(), (&Integer{Value: 1}).HashKey())
+	is.True((&Integer{Value: 1}).HashKey() != (&Boolean{Value: true}).HashKey())
+	big1, big2 := new(big.Int).Lsh(big.NewInt(1), 64), new(big.Int).Lsh(big.NewInt(1), 64)
+	is.Equal((&BigInteger{Value: big1}).HashKey(), (&BigInteger{Value: big2}).HashKey())
+	is.True((&BigInteger{Value: big1}).HashKey() != (&BigInteger{Value: new(big.Int).Neg(big1)}).HashKey())
+	is.True((&BigInteger{Value: big1}).HashKey() != (&Integer{Value: 5952119183343170477}).HashKey())
+}
+
+func TestNewInteger(t *testing.T) {
+	is := is.New(t)
+	is.Equal(NewInteger(big.NewInt(-5)), &Integer{Value: -5})
+	huge := new(big.Int).Lsh(big.NewInt(1), 64)
+	is.Equal(NewInteger(huge), &BigInteger{Value: huge})
+	is.Equal(NewInteger(huge).Inspect(), "18446744073709551616")
+}
+
+func TestInspect(t *testing.T) {
+	m := NewMap()
+	m.Set(&String{Value: "b"}, &Integer{Value: 1})
+	m.Set(&Integer{Value: 2}, &Null{})
+	m.Set(&String{Value: "b"}, &Integer{Value: 3})
+	point := &RecordType{Name: "P", Fields: []string{"x", "y"}}
+	cycle := &Array{Elements: []Object{&Integer{Value: 0}}}
+	cycle.Elements[0] = cycle
+	self := NewMap()
+	self.Set(&String{Value: "m"}, self)
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{&Integer{Value: -5}, "-5"},
+		{&Boolean{Value: true}, "T"},
+		{&String{Value: "a\"b"}, `"a\"b"`},
+		{&Null{}, "null"},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "x"}}}, `[1, "x"]`},
+		{m, `{"b": 3, 2: null}`},
+		{&Range{Low: 1, High: 3, HasHigh: true, Inclusive: true}, "1..=3"},
+		{&Range{Low: 2}, "2.."},
+		{point, "t P { x, y }"},
+		{&Record{RecordType: point, Fields: map[string]Object{"y": &Integer{Value: 2}, "x": &Integer{Value: 1}}}, "P{x: 1, y: 2}"},
+		{Errorf("bad %d", 1), "error: bad 1"},
+		{cycle, "[[...]]"},
+		{self, `{"m": {...}}`},
+		{&Array{Elements: []Object{cycle, cycle}}, "[[[...]], [[...]]]"},
+		{&Record{RecordType: point, Fields: map[string]Object{"x": cycle, "y": &Null{}}}, "P{x: [[...]], y: null}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			is := is.New(t)
+			is.Equal(tt.obj.Inspect(), tt.expected)
+		})
+	}
+}
+
+func TestErrorTrace(t *testing.T) {
+	is := is.New(t)
+	err := Errorf("boom")
+	err.Token = tokens.Token{Type: tokens.IDENT, Literal: "x", File: "a.brev", Row: 4, Col: 2}
+	err.Stack = []Frame{
+		{Function: "inner", Call: tokens.Token{Type: tokens.LBRK, Literal: "(", File: "a.brev", Row: 7, Col: 6}},
+		{Function: "util.outer", Call: tokens.Token{Type: tokens.LBRK, Literal: "(", File: "a.brev", Row: 9, Col: 10}},
+	}
+	is.Equal(err.Trace(), `error: boom
+
+inner(...)
+	a.brev:5:3
+util.outer(...)
+	a.brev:8:7
+main()
+	a.brev:10:11
+`)
+	is.Equal(Errorf("boom").Trace(), "error: boom\n\nmain()\n\t1:1\n")
+}
+
+func TestEnvironment(t *testing.T) {
+	is := is.New(t)
+	outer := NewEnvironment()
+	outer.Set("a", &Integer{Value: 1})
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("b", &Integer{Value: 2})
+	a, ok := inner.Get("a")
+	is.True(ok)
+	is.Equal(a.Inspect(), "1")
+	_, ok = outer.Get("b")
+	is.True(!ok)
+}
+
+func TestEnvironmentAssign(t *testing.T) {
+	is := is.New(t)
+	outer := NewEnvironment()
+	outer.Set("a", &Integer{Value: 1})
+	inner := NewEnclosedEnvironment(outer)
+	inner.Assign("a", &Integer{Value: 2})
+	inner.Assign("b", &Integer{Value: 3})
+	a, _ := outer.Get("a")
+	is.Equal(a.Inspect(), "2")
+	_, ok := outer.Get("b")
+	is.True(!ok) // b is local to inner
+	inner.Set("a", &Integer{Value: 4})
+	inner.Assign("a", &Integer{Value: 5})
+	a, _ = outer.Get("a")
+	is.Equal(a.Inspect(), "2") // shadowed a is rebound in inner
+	a, _ = inner.Get("a")
+	is.Equal(a.Inspect(), "5")
+}
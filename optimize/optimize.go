@@ -0,0 +1,345 @@
+// Package optimize simplifies Brev programs before they run.
+package optimize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/joerdav/brev/ast"
+	"github.com/joerdav/brev/tokens"
+)
+
+// A Warning is a problem found while optimizing that doesn't stop the
+// program from being run, but will probably make it fail.
+type Warning struct {
+	Message string
+	Token   tokens.Token
+}
+
+func (w Warning) String() string {
+	if w.Token.File != "" {
+		return fmt.Sprintf("%s (file: %s line: %d col: %d)", w.Message, w.Token.File, w.Token.Row, w.Token.Col)
+	}
+	return fmt.Sprintf("%s (line: %d col: %d)", w.Message, w.Token.Row, w.Token.Col)
+}
+
+// Program rewrites program in place:
+//
+//   - infix and prefix expressions of literals are folded, `2 * 3 + 1`
+//     becomes `7`
+//   - identities are removed, `x + 0`, `x * 1` and `!!b` become `x` and `b`,
+//     where x is known to be an int and b a bool so the result is unchanged
+//   - a name assigned a literal once, at the top level, is replaced by the
+//     literal in the statements after the assignment
+//
+// Nodes that replace others keep the position of the first token of what
+// they replace, so diagnostics still point at the source. Arithmetic that
+// would overflow is left to fail when the program runs.
+//
+// The warnings are for code that will fail when it runs, such as dividing by
+// a constant zero.
+func Program(program *ast.Program) []Warning {
+	o := &optimizer{assigned: assignments(program), consts: map[string]ast.Expression{}}
+	for i, stmt := range program.Statements {
+		program.Statements[i] = ast.Apply(stmt, o.pre, o.post).(ast.Statement)
+		o.record(program.Statements[i])
+	}
+	return o.warnings
+}
+
+type optimizer struct {
+	// assigned counts how many times each name is bound in the program.
+	assigned map[string]int
+	// consts holds the literal of each name assigned a constant once, by
+	// the statements seen so far.
+	consts   map[string]ast.Expression
+	warnings []Warning
+}
+
+// assignments counts the places each name is bound in the tree at node, by
+// assignment, pattern, loop, parameter, import or type declaration.
+func assignments(node ast.Node) map[string]int {
+	assigned := map[string]int{}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignmentStatement:
+			if n.Name != nil {
+				assigned[n.Name.Value]++
+			} else if root := root(n.Target); root != nil {
+				assigned[root.Value]++
+			}
+		case *ast.BindingPattern:
+			assigned[n.Name.Value]++
+		case *ast.ForStatement:
+			assigned[n.Variable.Value]++
+		case *ast.Parameter:
+			assigned[n.Name.Value]++
+		case *ast.ImportStatement:
+			if n.Name != nil {
+				assigned[n.Name.Value]++
+			}
+		case *ast.TypeDeclaration:
+			assigned[n.Name.Value]++
+		}
+		return true
+	})
+	return assigned
+}
+
+// root returns the identifier an index or selector target is rooted at,
+// `xs` in `xs[0].y`.
+func root(e ast.Expression) *ast.Identifier {
+	for {
+		switch x := e.(type) {
+		case *ast.Identifier:
+			return x
+		case *ast.IndexExpression:
+			e = x.Left
+		case *ast.SelectorExpression:
+			e = x.X
+		default:
+			return nil
+		}
+	}
+}
+
+// record remembers the value of stmt if it assigns a constant to a name
+// that is never bound anywhere else.
+func (o *optimizer) record(stmt ast.Statement) {
+	assign, ok := stmt.(*ast.AssignmentStatement)
+	if !ok || assign.Name == nil || assign.Operator != "" || o.assigned[assign.Name.Value] != 1 {
+		return
+	}
+	switch assign.Value.(type) {
+	case *ast.IntLiteral, *ast.Boolean, *ast.StringLiteral:
+		o.consts[assign.Name.Value] = assign.Value
+	}
+}
+
+func (o *optimizer) pre(c *ast.Cursor) bool {
+	// The value of a shorthand field is its name, it has to be written
+	// out to be replaced.
+	if fv, ok := c.Node().(*ast.FieldValue); ok && fv.Shorthand {
+		if value := o.constant(fv.Name); value != nil {
+			fv.Shorthand = false
+			fv.Value = value
+		}
+	}
+	return true
+}
+
+func (o *optimizer) post(c *ast.Cursor) bool {
+	switch n := c.Node().(type) {
+	case *ast.Identifier:
+		if !isReference(c) {
+			break
+		}
+		if value := o.constant(n); value != nil {
+			if pair, ok := c.Parent().(*ast.MapPair); ok {
+				pair.Shorthand = false
+			}
+			c.Replace(value)
+		}
+	case *ast.PrefixExpression:
+		if folded := o.prefix(n); folded != nil {
+			c.Replace(folded)
+		}
+	case *ast.InfixExpression:
+		if folded := o.infix(n); folded != nil {
+			c.Replace(folded)
+		}
+	}
+	return true
+}
+
+// isReference reports whether the identifier at c refers to a value, rather
+// than naming something being bound, a field or a type.
+func isReference(c *ast.Cursor) bool {
+	switch c.Parent().(type) {
+	case *ast.AssignmentStatement:
+		return c.Name() != "Name"
+	case *ast.SelectorExpression:
+		return c.Name() != "Sel"
+	case *ast.FieldValue:
+		return c.Name() != "Name"
+	case *ast.ForStatement:
+		return c.Name() != "Variable"
+	case *ast.RecordLiteral:
+		return c.Name() != "Type"
+	case *ast.PipeExpression:
+		// The right of a pipe must stay a function.
+		return c.Name() != "Right"
+	case *ast.Parameter, *ast.ImportStatement, *ast.TypeDeclaration, *ast.Field, *ast.BindingPattern:
+		return false
+	}
+	return true
+}
+
+// constant returns a copy of the constant named by id at its position, nil
+// if it isn't a constant.
+func (o *optimizer) constant(id *ast.Identifier) ast.Expression {
+	switch value := o.consts[id.Value].(type) {
+	case *ast.IntLiteral:
+		return &ast.IntLiteral{Token: moved(value.Token, id.Pos()), Value: value.Value, Big: value.Big}
+	case *ast.Boolean:
+		return &ast.Boolean{Token: moved(value.Token, id.Pos()), Value: value.Value}
+	case *ast.StringLiteral:
+		return &ast.StringLiteral{Token: moved(value.Token, id.Pos()), Value: value.Value}
+	}
+	return nil
+}
+
+// prefix returns what n simplifies to, nil if it can't be simplified.
+func (o *optimizer) prefix(n *ast.PrefixExpression) ast.Expression {
+	switch right := n.Right.(type) {
+	case *ast.IntLiteral:
+		if n.Operator == "-" && right.Big == nil && right.Value != math.MinInt64 {
+			return intLiteral(-right.Value, n.Pos())
+		}
+	case *ast.Boolean:
+		if n.Operator == "!" {
+			return boolean(!right.Value, n.Pos())
+		}
+	case *ast.PrefixExpression:
+		if n.Operator == "!" && right.Operator == "!" && isBool(right.Right) {
+			return right.Right
+		}
+	}
+	return nil
+}
+
+// infix returns what n simplifies to, nil if it can't be simplified.
+func (o *optimizer) infix(n *ast.InfixExpression) ast.Expression {
+	left, leftInt := smallInt(n.Left)
+	right, rightInt := smallInt(n.Right)
+	if rightInt && right.Value == 0 && (n.Operator == "/" || n.Operator == "%") {
+		o.warnings = append(o.warnings, Warning{Message: "division by zero", Token: n.Token})
+		return nil
+	}
+	switch {
+	case leftInt && rightInt:
+		return foldInts(n.Operator, left.Value, right.Value, n.Pos())
+	case rightInt && right.Value == 0 && (n.Operator == "+" || n.Operator == "-") && isInt(n.Left),
+		rightInt && right.Value == 1 && (n.Operator == "*" || n.Operator == "/") && isInt(n.Left):
+		return n.Left
+	case leftInt && left.Value == 0 && n.Operator == "+" && isInt(n.Right),
+		leftInt && left.Value == 1 && n.Operator == "*" && isInt(n.Right):
+		return n.Right
+	}
+	if left, ok := n.Left.(*ast.Boolean); ok {
+		if right, ok := n.Right.(*ast.Boolean); ok {
+			switch n.Operator {
+			case "==":
+				return boolean(left.Value == right.Value, n.Pos())
+			case "!=":
+				return boolean(left.Value != right.Value, n.Pos())
+			}
+		}
+	}
+	return nil
+}
+
+// isInt reports whether e is an int whenever it evaluates without an error:
+// an int literal, a negation, or arithmetic that only ints support.
+func isInt(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.IntLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return e.Operator == "-"
+	case *ast.InfixExpression:
+		switch e.Operator {
+		case "-", "*", "/", "%":
+			return true
+		case "+":
+			// Strings can be added too.
+			return isInt(e.Left) && isInt(e.Right)
+		}
+	}
+	return false
+}
+
+// isBool reports whether e is a bool whenever it evaluates without an error:
+// a bool literal, a negation or a comparison.
+func isBool(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.Boolean:
+		return true
+	case *ast.PrefixExpression:
+		return e.Operator == "!"
+	case *ast.InfixExpression:
+		switch e.Operator {
+		case "<", ">", "==", "!=":
+			return true
+		}
+	}
+	return false
+}
+
+// smallInt returns e if it is an int literal that fits in an int64. Larger
+// literals are left for the program to work out when it runs.
+func smallInt(e ast.Expression) (*ast.IntLiteral, bool) {
+	lit, ok := e.(*ast.IntLiteral)
+	return lit, ok && lit.Big == nil
+}
+
+// foldInts returns the literal `a op b` evaluates to, nil if op isn't known
+// or the result overflows.
+func foldInts(op string, a, b int64, pos tokens.Position) ast.Expression {
+	switch op {
+	case "+":
+		if b > 0 && a > math.MaxInt64-b || b < 0 && a < math.MinInt64-b {
+			return nil
+		}
+		return intLiteral(a+b, pos)
+	case "-":
+		if b < 0 && a > math.MaxInt64+b || b > 0 && a < math.MinInt64+b {
+			return nil
+		}
+		return intLiteral(a-b, pos)
+	case "*":
+		if a != 0 && b != 0 && ((a*b)/b != a || a == -1 && b == math.MinInt64 || b == -1 && a == math.MinInt64) {
+			return nil
+		}
+		return intLiteral(a*b, pos)
+	case "/", "%":
+		if a == math.MinInt64 && b == -1 {
+			return nil
+		}
+		if op == "/" {
+			return intLiteral(a/b, pos)
+		}
+		return intLiteral(a%b, pos)
+	case "<":
+		return boolean(a < b, pos)
+	case ">":
+		return boolean(a > b, pos)
+	case "==":
+		return boolean(a == b, pos)
+	case "!=":
+		return boolean(a != b, pos)
+	}
+	return nil
+}
+
+func intLiteral(v int64, pos tokens.Position) *ast.IntLiteral {
+	return &ast.IntLiteral{
+		Token: moved(tokens.Token{Type: tokens.NUMBER, Literal: strconv.FormatInt(v, 10)}, pos),
+		Value: v,
+	}
+}
+
+func boolean(v bool, pos tokens.Position) *ast.Boolean {
+	t := tokens.Token{Type: tokens.FALSE, Literal: tokens.FALSE}
+	if v {
+		t = tokens.Token{Type: tokens.TRUE, Literal: tokens.TRUE}
+	}
+	return &ast.Boolean{Token: moved(t, pos), Value: v}
+}
+
+// moved returns t at pos.
+func moved(t tokens.Token, pos tokens.Position) tokens.Token {
+	t.Row, t.Col, t.File = pos.Row, pos.Col, pos.File
+	return t
+}
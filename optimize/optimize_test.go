@@ -0,0 +1,106 @@
+package optimize
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/joerdav/brev/ast"
+	"github.com/joerdav/brev/evaluator"
+	"github.com/joerdav/brev/object"
+	"github.com/joerdav/brev/parser"
+	"github.com/joerdav/brev/printer"
+	"github.com/matryer/is"
+)
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{"fold", "x = 2 * 3 + 1", "x = 7\n"},
+		{"fold nested", "y = (10 - 4) / 2 * a", "y = 3 * a\n"},
+		{"fold prefix", "-(2 + 3) * -1", "5\n"},
+		{"fold comparisons", "[1 < 2, 2 > 3, 4 == 4, 5 != 5, T == F, !T != F]", "[T, F, T, F, F, F]\n"},
+		{"add zero", "[-a + 0, 0 + a * b, a / b - 0, 0 - -a]", "[-a, a * b, a / b, 0 - -a]\n"},
+		{"multiply one", "[-a * 1, 1 * (a - b), (a / b) / 1, 1 / -a]", "[-a, a - b, a / b, 1 / -a]\n"},
+		{"add zero to sums", "[(-a + -b) + 0, (a + -b) + 0]", "[-a + -b, a + -b + 0]\n"},
+		{"identity of unknown types", `s = "a"
+[s + 0, g() + 0, xs * 1, 1 * x, a - 0, b / 1]`, "s = \"a\"\n[\"a\" + 0, g() + 0, xs * 1, 1 * x, a - 0, b / 1]\n"},
+		{"double negation", "[!!(a < b), !!!b, !!T, !!(a == b)]", "[a < b, !b, T, a == b]\n"},
+		{"double negation of unknown types", "[!!b, !!5, !!g()]", "[!!b, !!5, !!g()]\n"},
+		{"identity after folding", "-a * (3 - 2) + (1 - 1)", "-a\n"},
+		{"propagate", "n = 2 * 5\ng(n + 1)", "n = 10\ng(11)\n"},
+		{"propagate strings and booleans", `s = "hi" ok = !F g(s, ok)`, "s = \"hi\"\nok = T\ng(\"hi\", T)\n"},
+		{"propagate into functions", "n = 3 g = f(x) { x * n }", "n = 3\ng = f(x) {\n\tx * 3\n}\n"},
+		{"propagate into shorthand", "n = 3 P{n}\n{n}", "n = 3\nP{n: 3}\n{\"n\": 3}\n"},
+		{"only after the assignment", "g(n) n = 1 g(n)", "g(n)\nn = 1\ng(1)\n"},
+		{"reassigned", "n = 1 n = 2 g(n)", "n = 1\nn = 2\ng(n)\n"},
+		{"compound assignment", "n = 1 n += 1 g(n)", "n = 1\nn += 1\ng(n)\n"},
+		{"assigned in a block", "n = 1 l x in xs { n = x } g(n)", "n = 1\nl x in xs {\n\tn = x\n}\ng(n)\n"},
+		{"shadowed by a parameter", "n = 1 g = f(n) { n }", "n = 1\ng = f(n) {\n\tn\n}\n"},
+		{"bound by a pattern", "n = 1 m v { n -> n }", "n = 1\nm v {\n\tn -> n,\n}\n"},
+		{"index target", "xs = 1 xs[0] = 2 g(xs)", "xs = 1\nxs[0] = 2\ng(xs)\n"},
+		{"not a reference", "y = 1 p.y p = P{y: y}", "y = 1\np.y\np = P{y: 1}\n"},
+		{"pipe target", "g = 1 x |> g", "g = 1\nx |> g\n"},
+		{"overflow", "9223372036854775807 + 1", "9223372036854775807 + 1\n"},
+		{"big ints", "18446744073709551616 * 1 + 0 - 18446744073709551616", "18446744073709551616 - 18446744073709551616\n"},
+		{"propagate big ints", "n = 18446744073709551616 g(-n)", "n = 18446744073709551616\ng(-18446744073709551616)\n"},
+		{"big int divisor", "x / 18446744073709551616", "x / 18446744073709551616\n"},
+		{"not a constant", "xs = [1] g(xs)", "xs = [1]\ng(xs)\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			program, err := parser.ParseString(tt.input)
+			is.NoErr(err)
+			is.Equal(len(Program(program)), 0)
+			var buf bytes.Buffer
+			is.NoErr(printer.Fprint(&buf, program))
+			is.Equal(tt.expected, buf.String())
+		})
+	}
+}
+
+func TestProgramKeepsResults(t *testing.T) {
+	tests := []string{
+		"!!5",
+		`s = "a" s + 0`,
+		"g = f() { T } g() + 0",
+		"xs = [1]\nxs * 1",
+		"x = 7 -x * 1 + 0",
+		"a = 2 b = 3 !!(a < b)",
+	}
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			is := is.New(t)
+			program, err := parser.ParseString(input)
+			is.NoErr(err)
+			expected := evaluator.Eval(context.Background(), program, object.NewEnvironment()).Inspect()
+			Program(program)
+			is.Equal(evaluator.Eval(context.Background(), program, object.NewEnvironment()).Inspect(), expected)
+		})
+	}
+}
+
+func TestProgramKeepsPositions(t *testing.T) {
+	is := is.New(t)
+	program, err := parser.ParseString("n = 1\nx = a + (2 * n)")
+	is.NoErr(err)
+	Program(program)
+	value := program.Statements[1].(*ast.AssignmentStatement).Value.(*ast.InfixExpression)
+	is.Equal(value.Right.Pos().String(), "1:9")
+	is.Equal(value.Left.Pos().String(), "1:4")
+}
+
+func TestDivisionByZero(t *testing.T) {
+	is := is.New(t)
+	program, err := parser.ParseString("a = 1 / 0\nb = x / (1 - 1)\nc = 0 / x")
+	is.NoErr(err)
+	warnings := Program(program)
+	is.Equal(len(warnings), 2)
+	is.Equal(warnings[0].String(), "division by zero (line: 0 col: 6)")
+	is.Equal(warnings[1].String(), "division by zero (line: 1 col: 6)")
+	var buf bytes.Buffer
+	is.NoErr(printer.Fprint(&buf, program))
+	is.Equal(buf.String(), "a = 1 / 0\nb = x / 0\nc = 0 / x\n")
+}
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"errors"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/joerdav/brev/ast"
+	"github.com/joerdav/brev/lexer"
+	"github.com/joerdav/brev/tokens"
+)
+
+// ParseString parses src as a complete program. The returned error is an
+// ErrorList if src has syntax errors, the program is returned either way.
+func ParseString(src string, opts ...Option) (*ast.Program, error) {
+	l := lexer.NewLexer(strings.NewReader(src))
+	p := New(&l, opts...)
+	program := p.ParseProgram()
+	return program, ErrorList(p.Errors()).Err()
+}
+
+// ParseFile parses the named file as a complete program, positions in the
+// result record filename as their File.
+func ParseFile(filename string, opts ...Option) (*ast.Program, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	l := lexer.NewFileLexer(filename, f)
+	p := New(&l, opts...)
+	program := p.ParseProgram()
+	return program, ErrorList(p.Errors()).Err()
+}
+
+// ParseExpr parses src, which must be a single expression. The result is a
+// program holding one *ast.ExpressionStatement.
+func ParseExpr(src string, opts ...Option) (*ast.Program, error) {
+	program, err := ParseString(src, opts...)
+	if err != nil {
+		return program, err
+	}
+	// The error is at the first statement that isn't the expression, or at
+	// the end of src if there are none.
+	at := tokens.Position{Row: math.MaxInt32}
+	stmts := program.Statements
+	switch {
+	case len(stmts) > 1:
+		at = stmts[1].Pos()
+	case len(stmts) == 1:
+		if _, ok := stmts[0].(*ast.ExpressionStatement); ok {
+			return program, nil
+		}
+		at = stmts[0].Pos()
+	}
+	return program, ErrorList{{Message: "expected a single expression", Token: tokenAt(src, at)}}
+}
+
+// tokenAt returns the token of src starting at pos, or the EOF token if no
+// token starts there or after it.
+func tokenAt(src string, pos tokens.Position) tokens.Token {
+	l := lexer.NewLexer(strings.NewReader(src))
+	for {
+		t := l.NextToken()
+		if t.Type == tokens.EOF || !t.Pos().Before(pos) {
+			return t
+		}
+	}
+}
+
+// ParseFiles parses each of the named files into a package, with opts. Every
+// file is parsed independently, so positions are relative to the file they
+// are in. The returned error is an ErrorList covering all of the files.
+func ParseFiles(filenames []string, opts ...Option) (*ast.Package, error) {
+	pkg := &ast.Package{Files: []*ast.Program{}}
+	var errs ErrorList
+	for _, filename := range filenames {
+		program, err := ParseFile(filename, opts...)
+		var list ErrorList
+		if err != nil && !errors.As(err, &list) {
+			return nil, err
+		}
+		pkg.Files = append(pkg.Files, program)
+		errs = append(errs, list...)
+	}
+	return pkg, errs.Err()
+}
@@ -1,8 +1,12 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/joerdav/brev/ast"
 	"github.com/joerdav/brev/lexer"
@@ -12,23 +16,55 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	PIPE
 	EQUALS
 	LESSGREATER
+	RANGE
 	SUM
 	PRODUCT
 	PREFIX
 	CALL
+	INDEX
 )
 
 var precedences = map[tokens.TokenType]int{
-	tokens.EQ:       EQUALS,
-	tokens.NE:       EQUALS,
-	tokens.LT:       LESSGREATER,
-	tokens.GT:       LESSGREATER,
-	tokens.ADD:      SUM,
-	tokens.SUB:      SUM,
-	tokens.SLASH:    PRODUCT,
-	tokens.ASTERISK: PRODUCT,
+	tokens.PIPE:       PIPE,
+	tokens.EQ:         EQUALS,
+	tokens.NE:         EQUALS,
+	tokens.LT:         LESSGREATER,
+	tokens.GT:         LESSGREATER,
+	tokens.RANGE:      RANGE,
+	tokens.RANGE_INCL: RANGE,
+	tokens.ADD:        SUM,
+	tokens.SUB:        SUM,
+	tokens.SLASH:      PRODUCT,
+	tokens.PERCENT:    PRODUCT,
+	tokens.ASTERISK:   PRODUCT,
+	tokens.LBRK:       CALL,
+	tokens.LBRC:       CALL,
+	tokens.LSQB:       INDEX,
+	tokens.DOT:        INDEX,
+}
+
+// Precedence returns the default precedence of the binary operator op, or
+// LOWEST if op isn't one. Operators added with WithInfix aren't included,
+// see Parser.Precedence.
+func Precedence(op tokens.TokenType) int {
+	if p, ok := precedences[op]; ok {
+		return p
+	}
+	return LOWEST
+}
+
+// assignOperators maps each assignment token to the arithmetic operator it
+// applies before assigning.
+var assignOperators = map[tokens.TokenType]string{
+	tokens.ASSIGN:          "",
+	tokens.ADD_ASSIGN:      "+",
+	tokens.SUB_ASSIGN:      "-",
+	tokens.ASTERISK_ASSIGN: "*",
+	tokens.SLASH_ASSIGN:    "/",
+	tokens.PERCENT_ASSIGN:  "%",
 }
 
 type (
@@ -36,15 +72,126 @@ type (
 	infixParseFn  func(ast.Expression) ast.Expression
 )
 
+// Associativity controls how a chain of operators with the same precedence
+// groups. `a - b - c` is left associative: `(a - b) - c`.
+type Associativity int
+
+const (
+	LeftAssoc Associativity = iota
+	RightAssoc
+)
+
+// Option configures a Parser created with New.
+type Option func(*Parser)
+
+// WithInfix registers tokenType as a binary operator with the given
+// precedence, producing an *ast.InfixExpression. Word operators such as `in`
+// or `matches` are lexed as identifiers, any identifier whose literal equals
+// tokenType is treated as the operator.
+func WithInfix(tokenType tokens.TokenType, precedence int, assoc Associativity) Option {
+	return func(p *Parser) {
+		p.operators[string(tokenType)] = tokenType
+		p.precedences[tokenType] = precedence
+		if assoc == RightAssoc {
+			p.rightAssoc[tokenType] = true
+		} else {
+			delete(p.rightAssoc, tokenType)
+		}
+		p.addInfixParser(tokenType, p.parseInfixExpression)
+	}
+}
+
+// WithPrefix registers tokenType as a unary operator, producing an
+// *ast.PrefixExpression.
+func WithPrefix(tokenType tokens.TokenType) Option {
+	return func(p *Parser) {
+		p.operators[string(tokenType)] = tokenType
+		p.addPrefixParser(tokenType, p.parsePrefixExpression)
+	}
+}
+
 type ParserError struct {
 	Message string
 	Token   tokens.Token
 }
 
 func (pe ParserError) String() string {
+	if pe.Token.File != "" {
+		return fmt.Sprintf("%s (file: %s line: %d col: %d)", pe.Message, pe.Token.File, pe.Token.Row, pe.Token.Col)
+	}
 	return fmt.Sprintf("%s (line: %d col: %d)", pe.Message, pe.Token.Row, pe.Token.Col)
 }
 
+func (pe ParserError) Error() string {
+	return pe.String()
+}
+
+// Incomplete reports whether the error was caused by the input ending early,
+// such as an unclosed `{` or a trailing operator, rather than by a mistake in
+// the input. More input may fix it.
+func (pe ParserError) Incomplete() bool {
+	return pe.Token.Type == tokens.EOF || isUnterminatedString(pe.Token)
+}
+
+func isUnterminatedString(t tokens.Token) bool {
+	return t.Type == tokens.ILLEGAL && strings.HasPrefix(t.Literal, `"`)
+}
+
+// ErrorList is the error returned by the Parse functions when the source
+// has one or more errors.
+type ErrorList []ParserError
+
+func (el ErrorList) Error() string {
+	msgs := make([]string, len(el))
+	for i, e := range el {
+		msgs[i] = e.String()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Incomplete reports whether every error in the list is Incomplete, meaning
+// the input so far is valid but unfinished.
+func (el ErrorList) Incomplete() bool {
+	for _, e := range el {
+		if !e.Incomplete() {
+			return false
+		}
+	}
+	return len(el) > 0
+}
+
+// IsIncomplete reports whether err is an ErrorList for input that is
+// unfinished rather than wrong.
+func IsIncomplete(err error) bool {
+	var el ErrorList
+	return errors.As(err, &el) && el.Incomplete()
+}
+
+// Err returns the list as an error, or nil if it is empty.
+func (el ErrorList) Err() error {
+	if len(el) == 0 {
+		return nil
+	}
+	return el
+}
+
+// WithComments records comments in the Comments field of the parsed
+// program and attaches them to nodes in its CommentMap, by default they are
+// discarded.
+func WithComments() Option {
+	return func(p *Parser) {
+		p.parseComments = true
+	}
+}
+
+// WithTrace writes an indented trace of the parse functions called, and the
+// token each started at, to w.
+func WithTrace(w io.Writer) Option {
+	return func(p *Parser) {
+		p.tracer = w
+	}
+}
+
 type Parser struct {
 	l *lexer.Lexer
 
@@ -55,24 +202,71 @@ type Parser struct {
 
 	prefixParseFns map[tokens.TokenType]prefixParseFn
 	infixParseFns  map[tokens.TokenType]infixParseFn
+
+	precedences map[tokens.TokenType]int
+	rightAssoc  map[tokens.TokenType]bool
+	operators   map[string]tokens.TokenType
+
+	// noRecordLit is set while parsing an expression that is followed by a
+	// block, so that `m p { ... }` isn't read as a record literal.
+	noRecordLit bool
+
+	parseComments bool
+	comments      []*ast.CommentGroup
+	// trailingGroup is set when the last comment group follows code on
+	// its line.
+	trailingGroup bool
+
+	tracer     io.Writer
+	traceDepth int
 }
 
-func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []ParserError{}}
-	p.nextToken()
-	p.nextToken()
+func New(l *lexer.Lexer, opts ...Option) *Parser {
+	p := &Parser{
+		l:           l,
+		errors:      []ParserError{},
+		precedences: map[tokens.TokenType]int{},
+		rightAssoc:  map[tokens.TokenType]bool{},
+		operators:   map[string]tokens.TokenType{},
+	}
+	for tt, prec := range precedences {
+		p.precedences[tt] = prec
+	}
 	p.addPrefixParser(tokens.IDENT, p.parseIdentifier)
 	p.addPrefixParser(tokens.NUMBER, p.parseIntLiteral)
+	p.addPrefixParser(tokens.STRING, p.parseStringLiteral)
+	p.addPrefixParser(tokens.TRUE, p.parseBoolean)
+	p.addPrefixParser(tokens.FALSE, p.parseBoolean)
+	p.addPrefixParser(tokens.MATCH, p.parseMatchExpression)
+	p.addPrefixParser(tokens.LSQB, p.parseArrayLiteral)
+	p.addPrefixParser(tokens.LBRC, p.parseMapLiteral)
+	p.addPrefixParser(tokens.LBRK, p.parseGroupedExpression)
+	p.addPrefixParser(tokens.RANGE, p.parseOpenRange)
+	p.addPrefixParser(tokens.RANGE_INCL, p.parseOpenRange)
+	p.addPrefixParser(tokens.FUNCTION, p.parseFunctionLiteral)
 	p.addPrefixParser(tokens.BANG, p.parsePrefixExpression)
 	p.addPrefixParser(tokens.SUB, p.parsePrefixExpression)
 	p.addInfixParser(tokens.ADD, p.parseInfixExpression)
 	p.addInfixParser(tokens.SUB, p.parseInfixExpression)
 	p.addInfixParser(tokens.SLASH, p.parseInfixExpression)
+	p.addInfixParser(tokens.PERCENT, p.parseInfixExpression)
 	p.addInfixParser(tokens.ASTERISK, p.parseInfixExpression)
 	p.addInfixParser(tokens.EQ, p.parseInfixExpression)
 	p.addInfixParser(tokens.NE, p.parseInfixExpression)
 	p.addInfixParser(tokens.LT, p.parseInfixExpression)
 	p.addInfixParser(tokens.GT, p.parseInfixExpression)
+	p.addInfixParser(tokens.LSQB, p.parseIndexExpression)
+	p.addInfixParser(tokens.LBRK, p.parseCallExpression)
+	p.addInfixParser(tokens.LBRC, p.parseRecordLiteral)
+	p.addInfixParser(tokens.DOT, p.parseSelectorExpression)
+	p.addInfixParser(tokens.PIPE, p.parsePipeExpression)
+	p.addInfixParser(tokens.RANGE, p.parseRangeExpression)
+	p.addInfixParser(tokens.RANGE_INCL, p.parseRangeExpression)
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.nextToken()
+	p.nextToken()
 	return p
 }
 
@@ -90,29 +284,97 @@ func (p *Parser) addInfixParser(tokenType tokens.TokenType, fn infixParseFn) {
 	p.infixParseFns[tokenType] = fn
 }
 
+// Precedence returns the precedence of the binary operator op in the grammar
+// of p, including operators added with WithInfix, or LOWEST if op isn't one.
+func (p *Parser) Precedence(op tokens.TokenType) int {
+	if prec, ok := p.precedences[op]; ok {
+		return prec
+	}
+	return LOWEST
+}
+
+// RightAssoc reports whether the binary operator op was added with WithInfix
+// as right associative.
+func (p *Parser) RightAssoc(op tokens.TokenType) bool {
+	return p.rightAssoc[op]
+}
+
 func (p *Parser) Errors() []ParserError {
 	return p.errors
 }
 
+// Incomplete reports whether parsing failed only because the input ended
+// early, see ErrorList.Incomplete.
+func (p *Parser) Incomplete() bool {
+	return ErrorList(p.errors).Incomplete()
+}
+
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	for p.peekToken.Type == tokens.COMMENT {
+		if p.parseComments {
+			p.addComment(p.peekToken)
+		}
+		p.peekToken = p.l.NextToken()
+	}
+	if p.peekToken.Type == tokens.IDENT {
+		if tt, ok := p.operators[p.peekToken.Literal]; ok {
+			p.peekToken.Type = tt
+		}
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
 	program := new(ast.Program)
+	program.Filename = p.l.Filename()
 	program.Statements = []ast.Statement{}
 
 	for p.curToken.Type != tokens.EOF {
 		stmt := p.parseStatement()
+		if imp, ok := stmt.(*ast.ImportStatement); ok && len(program.Imports()) != len(program.Statements) {
+			msg := "imports must come before other statements"
+			p.errors = append(p.errors, ParserError{Message: msg, Token: imp.Token})
+		}
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	// Positions of a program with errors may be missing, so its comments
+	// are kept but not attached.
+	if p.parseComments {
+		program.Comments = p.comments
+	}
+	if p.parseComments && len(p.errors) == 0 {
+		if len(p.comments) > 0 {
+			doc := p.comments[0]
+			if len(program.Statements) == 0 || doc.End().Row+1 < program.Statements[0].Pos().Row {
+				program.Doc = doc
+			}
+		}
+		program.CommentMap = ast.NewCommentMap(program, p.comments)
+	}
 	return program
 }
 
+// addComment adds the comment tok to the last comment group if it is on
+// the next line, otherwise it starts a new group. A comment following code
+// on the same line is a group on its own.
+func (p *Parser) addComment(tok tokens.Token) {
+	c := &ast.Comment{Token: tok}
+	trailing := p.curToken.Type != "" && p.curToken.Row == tok.Row
+	if n := len(p.comments); n > 0 && !trailing && !p.trailingGroup {
+		last := p.comments[n-1]
+		if last.End().Row+1 == tok.Row {
+			last.List = append(last.List, c)
+			return
+		}
+	}
+	p.comments = append(p.comments, &ast.CommentGroup{List: []*ast.Comment{c}})
+	p.trailingGroup = trailing
+}
+
 func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
@@ -120,6 +382,11 @@ func (p *Parser) parseIdentifier() ast.Expression {
 func (p *Parser) parseIntLiteral() ast.Expression {
 	lit := &ast.IntLiteral{Token: p.curToken}
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if lit.Big, _ = new(big.Int).SetString(p.curToken.Literal, 0); lit.Big != nil {
+			return lit
+		}
+	}
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as int", p.curToken.Literal)
 		p.errors = append(p.errors, ParserError{Message: msg, Token: p.curToken})
@@ -129,20 +396,301 @@ func (p *Parser) parseIntLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+func (p *Parser) parseBoolean() ast.Expression {
+	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(tokens.TRUE)}
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	al := &ast.ArrayLiteral{Token: p.curToken}
+	al.Elements = p.parseExpressionList(tokens.RSQB)
+	if al.Elements == nil {
+		return nil
+	}
+	al.Rbrack = p.curToken
+	return al
+}
+
+func (p *Parser) parseMapLiteral() ast.Expression {
+	ml := &ast.MapLiteral{Token: p.curToken, Pairs: []*ast.MapPair{}}
+	for !p.peekTokenIs(tokens.RBRC) {
+		p.nextToken()
+		pair := &ast.MapPair{Token: p.curToken}
+		if p.curTokenIs(tokens.IDENT) && (p.peekTokenIs(tokens.COMMA) || p.peekTokenIs(tokens.RBRC)) {
+			pair.Key = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+			pair.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			pair.Shorthand = true
+		} else {
+			if pair.Key = p.parseExpression(LOWEST); pair.Key == nil {
+				return nil
+			}
+			if !p.expectPeek(tokens.COLON) {
+				return nil
+			}
+			p.nextToken()
+			if pair.Value = p.parseExpression(LOWEST); pair.Value == nil {
+				return nil
+			}
+		}
+		ml.Pairs = append(ml.Pairs, pair)
+		if !p.peekTokenIs(tokens.RBRC) && !p.expectPeek(tokens.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	ml.Rbrace = p.curToken
+	return ml
+}
+
+// parseExpressionList parses comma separated expressions up to and including
+// the end token. It returns nil if any expression fails to parse.
+func (p *Parser) parseExpressionList(end tokens.TokenType) []ast.Expression {
+	defer p.allowRecordLit(true)()
+	list := []ast.Expression{}
+	for !p.peekTokenIs(end) {
+		p.nextToken()
+		exp := p.parseExpression(LOWEST)
+		if exp == nil {
+			return nil
+		}
+		list = append(list, exp)
+		if !p.peekTokenIs(end) && !p.expectPeek(tokens.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	return list
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	defer p.trace("parseIndexExpression")()
+	defer p.allowRecordLit(true)()
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	p.nextToken()
+	if exp.Index = p.parseExpression(LOWEST); exp.Index == nil {
+		return nil
+	}
+	if !p.expectPeek(tokens.RSQB) {
+		return nil
+	}
+	exp.Rbrack = p.curToken
+	return exp
+}
+
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	defer p.allowRecordLit(true)()
+	p.nextToken()
+	exp := p.parseExpression(LOWEST)
+	if !p.expectPeek(tokens.RBRK) {
+		return nil
+	}
+	return exp
+}
+
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	defer p.trace("parseFunctionLiteral")()
+	fn := &ast.FunctionLiteral{Token: p.curToken}
+	if !p.expectPeek(tokens.LBRK) {
+		return nil
+	}
+	if fn.Parameters = p.parseParameters(); fn.Parameters == nil {
+		return nil
+	}
+	if p.peekTokenIs(tokens.ARROW) {
+		p.nextToken()
+		p.nextToken()
+		if fn.ReturnType = p.parseType(); fn.ReturnType == nil {
+			return nil
+		}
+	}
+	if !p.expectPeek(tokens.LBRC) {
+		return nil
+	}
+	fn.Body = p.parseBlockStatement()
+	return fn
+}
+
+func (p *Parser) parseParameters() []*ast.Parameter {
+	params := []*ast.Parameter{}
+	for !p.peekTokenIs(tokens.RBRK) {
+		if !p.expectPeek(tokens.IDENT) {
+			return nil
+		}
+		param := &ast.Parameter{Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+		if p.peekTokenIs(tokens.COLON) {
+			p.nextToken()
+			p.nextToken()
+			if param.Type = p.parseType(); param.Type == nil {
+				return nil
+			}
+		}
+		params = append(params, param)
+		if !p.peekTokenIs(tokens.RBRK) && !p.expectPeek(tokens.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	return params
+}
+
+// allowRecordLit sets whether `{` following an expression starts a record
+// literal, it returns a function that restores the previous setting. Record
+// literals are always allowed inside brackets, where `{` can't start a block.
+func (p *Parser) allowRecordLit(allowed bool) func() {
+	prev := p.noRecordLit
+	p.noRecordLit = !allowed
+	return func() { p.noRecordLit = prev }
+}
+
+func (p *Parser) parseRecordLiteral(typ ast.Expression) ast.Expression {
+	switch typ.(type) {
+	case nil:
+		// The type failed to parse and has already been reported.
+		return nil
+	case *ast.Identifier, *ast.SelectorExpression:
+	default:
+		msg := fmt.Sprintf("%s is not a type name", typ)
+		p.errors = append(p.errors, ParserError{Message: msg, Token: p.curToken})
+		return nil
+	}
+	defer p.allowRecordLit(true)()
+	rl := &ast.RecordLiteral{Token: p.curToken, Type: typ, Fields: []*ast.FieldValue{}}
+	for !p.peekTokenIs(tokens.RBRC) {
+		if !p.expectPeek(tokens.IDENT) {
+			return nil
+		}
+		field := &ast.FieldValue{Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+		if p.peekTokenIs(tokens.COLON) {
+			p.nextToken()
+			p.nextToken()
+			if field.Value = p.parseExpression(LOWEST); field.Value == nil {
+				return nil
+			}
+		} else {
+			field.Value = field.Name
+			field.Shorthand = true
+		}
+		rl.Fields = append(rl.Fields, field)
+		if !p.peekTokenIs(tokens.RBRC) && !p.expectPeek(tokens.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	rl.Rbrace = p.curToken
+	return rl
+}
+
+func (p *Parser) parseSelectorExpression(x ast.Expression) ast.Expression {
+	exp := &ast.SelectorExpression{Token: p.curToken, X: x}
+	if !p.expectPeek(tokens.IDENT) {
+		return nil
+	}
+	exp.Sel = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return exp
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	defer p.trace("parseCallExpression")()
+	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+	exp.Arguments = p.parseExpressionList(tokens.RBRK)
+	if exp.Arguments == nil {
+		return nil
+	}
+	exp.Rparen = p.curToken
+	return exp
+}
+
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	defer p.trace("parsePipeExpression")()
+	exp := &ast.PipeExpression{Token: p.curToken, Left: left}
+	precedence := p.curPrecedence()
+	p.nextToken()
+	exp.Right = p.parseExpression(precedence)
+	if exp.Right == nil {
+		return nil
+	}
+	return exp
+}
+
+func (p *Parser) parseRangeExpression(low ast.Expression) ast.Expression {
+	exp := &ast.RangeExpression{
+		Token:     p.curToken,
+		Low:       low,
+		Inclusive: p.curTokenIs(tokens.RANGE_INCL),
+	}
+	return p.parseRangeEnd(exp)
+}
+
+// parseOpenRange parses a range without a start, `..end` or `..=end`.
+func (p *Parser) parseOpenRange() ast.Expression {
+	exp := &ast.RangeExpression{
+		Token:     p.curToken,
+		Inclusive: p.curTokenIs(tokens.RANGE_INCL),
+	}
+	return p.parseRangeEnd(exp)
+}
+
+func (p *Parser) parseRangeEnd(exp *ast.RangeExpression) ast.Expression {
+	switch p.peekToken.Type {
+	case tokens.RSQB, tokens.RBRK, tokens.RBRC, tokens.COMMA, tokens.EOF:
+		if exp.Inclusive {
+			msg := "inclusive range must have an end"
+			p.errors = append(p.errors, ParserError{Message: msg, Token: exp.Token})
+			return nil
+		}
+		return exp
+	}
+	precedence := p.curPrecedence()
+	p.nextToken()
+	if exp.High = p.parseExpression(precedence); exp.High == nil {
+		return nil
+	}
+	return exp
+}
+
 func (p *Parser) parseStatement() ast.Statement {
-	if p.curTokenIs(tokens.IDENT) && p.peekTokenIs(tokens.ASSIGN) {
+	defer p.trace("parseStatement")()
+	switch {
+	case p.curTokenIs(tokens.IDENT) && (p.peekTokenIs(tokens.ASSIGN) || p.peekTokenIs(tokens.COLON)):
 		return p.parseAssignment()
+	case p.curTokenIs(tokens.LOOP):
+		return p.parseForStatement()
+	case p.curTokenIs(tokens.TYPE):
+		return p.parseTypeDeclaration()
+	case p.curTokenIs(tokens.IMPORT):
+		return p.parseImportStatement()
+	}
+	stmt := p.parseExpressionStatement()
+	_, assign := assignOperators[p.peekToken.Type]
+	switch {
+	case p.peekTokenIs(tokens.COMMA), p.peekTokenIs(tokens.ASSIGN) && isCompositeLiteral(stmt.Expression):
+		return p.parsePatternAssignment(stmt.Expression)
+	case assign:
+		return p.parseAssignmentTo(stmt.Expression)
+	}
+	return stmt
+}
+
+func isCompositeLiteral(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.ArrayLiteral, *ast.MapLiteral:
+		return true
 	}
-	return p.parseExpressionStatement()
+	return false
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+	defer p.trace("parseExpressionStatement")()
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(LOWEST)
 	return stmt
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	defer p.trace("parseExpression")()
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken)
@@ -150,6 +698,14 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	}
 	leftExp := prefix()
 	for precedence < p.peekPrecedence() {
+		if p.peekTokenIs(tokens.LBRC) && p.noRecordLit {
+			return leftExp
+		}
+		if p.peekIsBracket() && p.peekToken.Row != p.curToken.Row {
+			// A bracket starting a new line begins a new statement rather
+			// than indexing or calling the previous one.
+			return leftExp
+		}
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			return leftExp
@@ -160,14 +716,152 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExp
 }
 
-func (p *Parser) parseAssignment() *ast.AssignmentStatement {
+func (p *Parser) parseAssignment() ast.Statement {
+	defer p.trace("parseAssignment")()
 	stmt := new(ast.AssignmentStatement)
-	if !p.peekTokenIs(tokens.ASSIGN) {
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekTokenIs(tokens.COLON) {
+		p.nextToken()
+		p.nextToken()
+		if stmt.Type = p.parseType(); stmt.Type == nil {
+			return nil
+		}
+	}
+	if !p.expectPeek(tokens.ASSIGN) {
+		return nil
+	}
+	stmt.Token = p.curToken
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	defer p.trace("parseForStatement")()
+	stmt := &ast.ForStatement{Token: p.curToken}
+	if !p.expectPeek(tokens.IDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(tokens.IN) {
+		return nil
+	}
+	p.nextToken()
+	restore := p.allowRecordLit(false)
+	stmt.Iterable = p.parseExpression(LOWEST)
+	restore()
+	if stmt.Iterable == nil {
+		return nil
+	}
+	if !p.expectPeek(tokens.LBRC) {
+		return nil
+	}
+	stmt.Body = p.parseBlockStatement()
+	return stmt
+}
+
+func (p *Parser) parseImportStatement() ast.Statement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+	if p.peekTokenIs(tokens.IDENT) {
+		p.nextToken()
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+	if !p.expectPeek(tokens.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	if stmt.Path.Value == "" {
+		msg := "import path must not be empty"
+		p.errors = append(p.errors, ParserError{Message: msg, Token: p.curToken})
+		return nil
+	}
+	return stmt
+}
+
+func (p *Parser) parseTypeDeclaration() ast.Statement {
+	decl := &ast.TypeDeclaration{Token: p.curToken, Fields: []*ast.Field{}}
+	if !p.expectPeek(tokens.IDENT) {
+		return nil
+	}
+	decl.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(tokens.LBRC) {
+		return nil
+	}
+	seen := map[string]bool{}
+	for !p.peekTokenIs(tokens.RBRC) {
+		if !p.expectPeek(tokens.IDENT) {
+			return nil
+		}
+		field := &ast.Field{Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+		if seen[field.Name.Value] {
+			msg := fmt.Sprintf("duplicate field %s in type %s", field.Name, decl.Name)
+			p.errors = append(p.errors, ParserError{Message: msg, Token: p.curToken})
+			return nil
+		}
+		seen[field.Name.Value] = true
+		if p.peekTokenIs(tokens.COLON) {
+			p.nextToken()
+			p.nextToken()
+			if field.Type = p.parseType(); field.Type == nil {
+				return nil
+			}
+		}
+		decl.Fields = append(decl.Fields, field)
+		if !p.peekTokenIs(tokens.RBRC) && !p.expectPeek(tokens.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	decl.Rbrace = p.curToken
+	return decl
+}
+
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	defer p.trace("parseBlockStatement")()
+	defer p.allowRecordLit(true)()
+	block := &ast.BlockStatement{Token: p.curToken, Statements: []ast.Statement{}}
+	p.nextToken()
+	for !p.curTokenIs(tokens.RBRC) {
+		if p.curTokenIs(tokens.EOF) {
+			msg := fmt.Sprintf("expected %s to close block, got %s", tokens.RBRC, tokens.EOF)
+			p.errors = append(p.errors, ParserError{Message: msg, Token: p.curToken})
+			return block
+		}
+		stmt := p.parseStatement()
+		if imp, ok := stmt.(*ast.ImportStatement); ok {
+			msg := "imports must be at the top level of a file"
+			p.errors = append(p.errors, ParserError{Message: msg, Token: imp.Token})
+		}
+		if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+		p.nextToken()
+	}
+	block.Rbrace = p.curToken
+	return block
+}
+
+// parseAssignmentTo parses a plain or compound assignment whose left hand
+// side has already been parsed as an expression.
+func (p *Parser) parseAssignmentTo(left ast.Expression) ast.Statement {
+	defer p.trace("parseAssignmentTo")()
+	if left == nil {
+		return nil
+	}
+	stmt := new(ast.AssignmentStatement)
+	switch left := left.(type) {
+	case *ast.Identifier:
+		stmt.Name = left
+	case *ast.IndexExpression, *ast.SelectorExpression:
+		stmt.Target = left
+	default:
+		msg := fmt.Sprintf("cannot assign to %s, expected an identifier, index or selector expression", left)
+		p.errors = append(p.errors, ParserError{Message: msg, Token: p.peekToken})
 		return nil
 	}
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	p.nextToken()
 	stmt.Token = p.curToken
+	stmt.Operator = assignOperators[p.curToken.Type]
 	p.nextToken()
 	stmt.Value = p.parseExpression(LOWEST)
 	return stmt
@@ -175,10 +869,14 @@ func (p *Parser) parseAssignment() *ast.AssignmentStatement {
 
 func (p *Parser) noPrefixParseFnError(t tokens.Token) {
 	msg := fmt.Sprintf("prefix %s not recognised", t.Type)
+	if isUnterminatedString(t) {
+		msg = "string literal not terminated"
+	}
 	p.errors = append(p.errors, ParserError{Token: t, Message: msg})
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
+	defer p.trace("parsePrefixExpression")()
 	exp := &ast.PrefixExpression{
 		Token:    p.curToken,
 		Operator: p.curToken.Literal,
@@ -189,12 +887,16 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 }
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	defer p.trace("parseInfixExpression")()
 	exp := &ast.InfixExpression{
 		Token:    p.curToken,
 		Operator: p.curToken.Literal,
 		Left:     left,
 	}
 	precedence := p.curPrecedence()
+	if p.rightAssoc[p.curToken.Type] {
+		precedence--
+	}
 	p.nextToken()
 	exp.Right = p.parseExpression(precedence)
 	return exp
@@ -209,15 +911,19 @@ func (p *Parser) curTokenIs(t tokens.TokenType) bool {
 	return p.curToken.Type == t
 }
 
+func (p *Parser) peekIsBracket() bool {
+	return p.peekTokenIs(tokens.LSQB) || p.peekTokenIs(tokens.LBRK) || p.peekTokenIs(tokens.LBRC)
+}
+
 func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
+	if p, ok := p.precedences[p.peekToken.Type]; ok {
 		return p
 	}
 	return LOWEST
 }
 
 func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
+	if p, ok := p.precedences[p.curToken.Type]; ok {
 		return p
 	}
 	return LOWEST
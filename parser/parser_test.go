@@ -1,11 +1,16 @@
 package parser
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/joerdav/brev/ast"
+	"github.com/joerdav/brev/ast/asttest"
 	"github.com/joerdav/brev/lexer"
 	"github.com/joerdav/brev/tokens"
 	"github.com/matryer/is"
@@ -168,6 +173,35 @@ func TestNumberExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntLiteral(t *testing.T) {
+	tests := []struct {
+		input, expected string
+	}{
+		{"9223372036854775807", "9223372036854775807"},
+		{"9223372036854775808", "9223372036854775808"},
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			program, err := ParseExpr(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expr := program.Statements[0].(*ast.ExpressionStatement).Expression
+			lit, ok := expr.(*ast.IntLiteral)
+			if !ok {
+				t.Fatalf("expected *ast.IntLiteral, got %T", expr)
+			}
+			if lit.String() != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, lit.String())
+			}
+			if (lit.Big != nil) != (tt.input != "9223372036854775807") {
+				t.Errorf("expected only literals too large for an int64 to be big, got %v", lit.Big)
+			}
+		})
+	}
+}
+
 func TestPrefixExpression(t *testing.T) {
 	input := "-5"
 	l := lexer.NewLexer(strings.NewReader(input))
@@ -199,6 +233,84 @@ func TestPrefixExpression(t *testing.T) {
 	}
 }
 
+func TestParseShapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected *ast.Program
+	}{
+		{"a = 5\nb = a", asttest.Program(
+			asttest.Assign("a", asttest.Int(5)),
+			asttest.Assign("b", asttest.Ident("a")),
+		)},
+		{"-a * (b + 2)", asttest.Program(asttest.Expr(
+			asttest.Infix(asttest.Prefix("-", asttest.Ident("a")), "*", asttest.Infix(asttest.Ident("b"), "+", asttest.Int(2))),
+		))},
+		{`x: [str]? = ["a", T]`, asttest.Program(
+			asttest.TypedAssign("x", asttest.Optional(asttest.List(asttest.Named("str"))), asttest.Array(asttest.Str("a"), asttest.Bool(true))),
+		)},
+		{`xs[1..=n].y -= {"k": 1, v}`, asttest.Program(asttest.AssignTo(
+			asttest.Selector(asttest.Index(asttest.Ident("xs"), asttest.InclusiveRange(asttest.Int(1), asttest.Ident("n"))), "y"),
+			"-",
+			asttest.Map(asttest.Pair(asttest.Str("k"), asttest.Int(1)), asttest.ShortPair("v")),
+		))},
+		{"a, [b, _], {c} = 1, xs[..2], ys", asttest.Program(asttest.PatternAssign(
+			[]ast.Pattern{
+				asttest.Bind("a"),
+				asttest.ArrayPattern(asttest.Bind("b"), asttest.Wildcard()),
+				asttest.MapPattern(asttest.ShortPatternPair("c")),
+			},
+			asttest.Int(1), asttest.Index(asttest.Ident("xs"), asttest.Range(nil, asttest.Int(2))), asttest.Ident("ys"),
+		))},
+		{"add = f(a: int, b) -> f(int) -> int? { a + b }", asttest.Program(asttest.Assign("add", asttest.Func(
+			[]*ast.Parameter{asttest.Param("a", asttest.Named("int")), asttest.Param("b", nil)},
+			asttest.FuncType(asttest.Optional(asttest.Named("int")), asttest.Named("int")),
+			asttest.Expr(asttest.Infix(asttest.Ident("a"), "+", asttest.Ident("b"))),
+		)))},
+		{"xs |> map(g) |> len", asttest.Program(asttest.Expr(asttest.Pipe(
+			asttest.Pipe(asttest.Ident("xs"), asttest.Call(asttest.Ident("map"), asttest.Ident("g"))),
+			asttest.Ident("len"),
+		)))},
+		{`im u "lib/u"` + "\nt P { x: {str: int}, y }\np = u.P{x: {}, y}", asttest.Program(
+			asttest.Import("u", "lib/u"),
+			asttest.TypeDecl("P", asttest.Field("x", asttest.MapOf(asttest.Named("str"), asttest.Named("int"))), asttest.Field("y", nil)),
+			asttest.Assign("p", asttest.Record(
+				asttest.Selector(asttest.Ident("u"), "P"),
+				asttest.FieldValue("x", asttest.Map()),
+				asttest.ShortField("y"),
+			)),
+		)},
+		{"l n in 0..10 { total += n }", asttest.Program(asttest.For(
+			"n", asttest.Range(asttest.Int(0), asttest.Int(10)),
+			asttest.AssignTo(asttest.Ident("total"), "+", asttest.Ident("n")),
+		))},
+		{`m v { -1 -> "neg", {"k": [x]} i x > 0 -> x, _ -> 0 }`, asttest.Program(asttest.Expr(asttest.Match(
+			asttest.Ident("v"),
+			asttest.Arm(asttest.Literal(asttest.Prefix("-", asttest.Int(1))), nil, asttest.Str("neg")),
+			asttest.Arm(
+				asttest.MapPattern(asttest.PatternPair(asttest.Str("k"), asttest.ArrayPattern(asttest.Bind("x")))),
+				asttest.Infix(asttest.Ident("x"), ">", asttest.Int(0)),
+				asttest.Ident("x"),
+			),
+			asttest.Arm(asttest.Wildcard(), nil, asttest.Int(0)),
+		)))},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			actual, err := ParseString(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ast.Equal(actual, tt.expected, ast.IgnorePositions()) {
+				var got, want strings.Builder
+				ast.FprintTree(&got, actual)
+				ast.FprintTree(&want, tt.expected)
+				t.Fatalf("got:\n%s\nwant:\n%s", &got, &want)
+			}
+		})
+	}
+}
+
 func TestInfixExpressions(t *testing.T) {
 	tests := []struct {
 		input             string
@@ -208,6 +320,7 @@ func TestInfixExpressions(t *testing.T) {
 		{"5+5", 5, 5, "+"},
 		{"5-5", 5, 5, "-"},
 		{"5/5", 5, 5, "/"},
+		{"5%5", 5, 5, "%"},
 		{"5*5", 5, 5, "*"},
 		{"5>5", 5, 5, ">"},
 		{"5<5", 5, 5, "<"},
@@ -242,3 +355,1197 @@ func TestInfixExpressions(t *testing.T) {
 		})
 	}
 }
+
+func TestCustomOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		opts     []Option
+		expected string
+	}{
+		{"x in xs", []Option{WithInfix("in", EQUALS, LeftAssoc)}, "xinxs"},
+		{"a + b matches c", []Option{WithInfix("matches", EQUALS, LeftAssoc)}, "a+bmatchesc"},
+		{"a pow b pow c", []Option{WithInfix("pow", PRODUCT, RightAssoc)}, "apowbpowc"},
+		{"4 % 2 + 1", []Option{WithInfix(tokens.PERCENT, LOWEST+1, LeftAssoc)}, "4%2+1"},
+		{"not a", []Option{WithPrefix("not")}, "nota"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l, tt.opts...)
+			actual := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				for _, e := range p.Errors() {
+					t.Error(e)
+				}
+				t.Errorf("got parser errors")
+			}
+			is.Equal(len(actual.Statements), 1)
+			is.Equal(actual.String(), tt.expected)
+		})
+	}
+}
+
+func TestCustomOperatorAssociativity(t *testing.T) {
+	is := is.New(t)
+	l := lexer.NewLexer(strings.NewReader("a pow b pow c"))
+	p := New(&l, WithInfix("pow", PRODUCT, RightAssoc))
+	actual := p.ParseProgram()
+	is.Equal(len(p.Errors()), 0)
+	stmt := actual.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.InfixExpression)
+	is.True(ok)
+	is.Equal(exp.Left.String(), "a")
+	right, ok := exp.Right.(*ast.InfixExpression)
+	is.True(ok)
+	is.Equal(right.String(), "bpowc")
+
+	l = lexer.NewLexer(strings.NewReader("a - b - c"))
+	p = New(&l)
+	actual = p.ParseProgram()
+	is.Equal(len(p.Errors()), 0)
+	stmt = actual.Statements[0].(*ast.ExpressionStatement)
+	exp, ok = stmt.Expression.(*ast.InfixExpression)
+	is.True(ok)
+	is.Equal(exp.Right.String(), "c")
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `m value {
+	0 -> "zero",
+	-1 -> "minus one",
+	[x, y] -> x + y,
+	{"name": n, "age": _} -> n,
+	n i n > 10 -> T,
+	_ -> "other",
+}`
+	is := is.New(t)
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := New(&l)
+	actual := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, e := range p.Errors() {
+			t.Error(e)
+		}
+		t.Fatalf("got parser errors")
+	}
+	is.Equal(len(actual.Statements), 1)
+	stmt, ok := actual.Statements[0].(*ast.ExpressionStatement)
+	is.True(ok)
+	exp, ok := stmt.Expression.(*ast.MatchExpression)
+	is.True(ok)
+	is.Equal(exp.Subject.String(), "value")
+	is.Equal(len(exp.Arms), 6)
+
+	lit, ok := exp.Arms[0].Pattern.(*ast.LiteralPattern)
+	is.True(ok)
+	is.Equal(lit.Value.(*ast.IntLiteral).Value, int64(0))
+	is.Equal(exp.Arms[0].Body.(*ast.StringLiteral).Value, "zero")
+
+	_, ok = exp.Arms[1].Pattern.(*ast.LiteralPattern)
+	is.True(ok)
+
+	arr, ok := exp.Arms[2].Pattern.(*ast.ArrayPattern)
+	is.True(ok)
+	is.Equal(len(arr.Elements), 2)
+	is.Equal(arr.Elements[0].(*ast.BindingPattern).Name.Value, "x")
+
+	mp, ok := exp.Arms[3].Pattern.(*ast.MapPattern)
+	is.True(ok)
+	is.Equal(len(mp.Pairs), 2)
+	is.Equal(mp.Pairs[0].Key.(*ast.StringLiteral).Value, "name")
+	_, ok = mp.Pairs[1].Value.(*ast.WildcardPattern)
+	is.True(ok)
+
+	_, ok = exp.Arms[4].Pattern.(*ast.BindingPattern)
+	is.True(ok)
+	is.Equal(exp.Arms[4].Guard.String(), "n>10")
+	_, ok = exp.Arms[4].Body.(*ast.Boolean)
+	is.True(ok)
+
+	_, ok = exp.Arms[5].Pattern.(*ast.WildcardPattern)
+	is.True(ok)
+	is.Equal(exp.String(), `m value { 0 -> "zero", -1 -> "minus one", [x, y] -> x+y, {"name": n, "age": _} -> n, n i n>10 -> T, _ -> "other" }`)
+}
+
+func TestMatchErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`m v { _ -> 1, 0 -> 2 }`, "unreachable match arm 0, already matched by _ (line: 0 col: 14)"},
+		{`m v { x -> 1, _ -> 2 }`, "unreachable match arm _, already matched by x (line: 0 col: 14)"},
+		{`m v { 1 -> 1, 1 -> 2 }`, "unreachable match arm 1, already matched by 1 (line: 0 col: 14)"},
+		{`m v { [a, _] -> 1, [1, 2] -> 2 }`, "unreachable match arm [1, 2], already matched by [a, _] (line: 0 col: 19)"},
+		{`m v { {"a": _} -> 1, {"a": 1, "b": 2} -> 2 }`, `unreachable match arm {"a": 1, "b": 2}, already matched by {"a": _} (line: 0 col: 21)`},
+		{`m v { 1 + -> 1 }`, "expected next token ->, got + (line: 0 col: 8)"},
+		{`m v { x 1 }`, "expected next token ->, got number (line: 0 col: 8)"},
+		{`m v { {a: 1} -> 1 }`, "expected map pattern key, got ident (line: 0 col: 7)"},
+		{`m v { n i -> 1 }`, "prefix -> not recognised (line: 0 col: 10)"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			p.ParseProgram()
+			is.True(len(p.Errors()) > 0)
+			is.Equal(p.Errors()[0].String(), tt.expected)
+		})
+	}
+}
+
+func TestMatchArmWithoutGuard(t *testing.T) {
+	is := is.New(t)
+	program, err := ParseString("m x { n i ) -> 1 }")
+	is.True(err != nil)
+	ast.Inspect(program, func(n ast.Node) bool {
+		if arm, ok := n.(*ast.MatchArm); ok {
+			is.True(arm.Guard != nil) // arm with a missing guard
+		}
+		return true
+	})
+}
+
+func TestGuardedArmsAreReachable(t *testing.T) {
+	is := is.New(t)
+	l := lexer.NewLexer(strings.NewReader(`m v { x i x > 1 -> 1, [_, _] i T -> 2, x -> 3 }`))
+	p := New(&l)
+	p.ParseProgram()
+	is.Equal(len(p.Errors()), 0)
+}
+
+func TestPatternAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		targets  int
+		expected string
+	}{
+		{"a, b = b, a", 2, "a, b = b, a"},
+		{"[x, y] = pair", 1, "[x, y] = pair"},
+		{"{name, age} = person", 1, "{name, age} = person"},
+		{`{"first": f1, rest} = person`, 1, `{"first": f1, rest} = person`},
+		{"_, [a, {b}] = 1, pair", 2, "_, [a, {b}] = 1, pair"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			actual := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				for _, e := range p.Errors() {
+					t.Error(e)
+				}
+				t.Fatalf("got parser errors")
+			}
+			is.Equal(len(actual.Statements), 1)
+			stmt, ok := actual.Statements[0].(*ast.PatternAssignmentStatement)
+			is.True(ok)
+			is.Equal(len(stmt.Targets), tt.targets)
+			is.Equal(stmt.Token.Type, tokens.TokenType(tokens.ASSIGN))
+			is.Equal(stmt.String(), tt.expected)
+		})
+	}
+}
+
+func TestPatternAssignmentErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a, b = 1", "assignment mismatch: 2 targets but 1 values (line: 0 col: 5)"},
+		{"a = 1, 2", "prefix , not recognised (line: 0 col: 5)"},
+		{"[a] = 1, 2", "assignment mismatch: 1 targets but 2 values (line: 0 col: 4)"},
+		{"[a, 1] = pair", "cannot assign to literal 1 (line: 0 col: 4)"},
+		{"a, b c", "expected next token =, got ident (line: 0 col: 5)"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			p.ParseProgram()
+			is.True(len(p.Errors()) > 0)
+			is.Equal(p.Errors()[0].String(), tt.expected)
+		})
+	}
+}
+
+func TestCollectionLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[]", "[]"},
+		{"[1, 2 + 3, x]", "[1, 2+3, x]"},
+		{"{}", "{}"},
+		{`{"a": 1, b: 2 * 3}`, `{"a": 1, b: 2*3}`},
+		{"{name, age}", "{name, age}"},
+		{"xs[0]", "xs[0]"},
+		{`dict["k"][1 + 1]`, `dict["k"][1+1]`},
+		{"[1, 2][0]", "[1, 2][0]"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			actual := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				for _, e := range p.Errors() {
+					t.Error(e)
+				}
+				t.Fatalf("got parser errors")
+			}
+			is.Equal(len(actual.Statements), 1)
+			_, ok := actual.Statements[0].(*ast.ExpressionStatement)
+			is.True(ok)
+			is.Equal(actual.String(), tt.expected)
+		})
+	}
+}
+
+func TestIndexOnNewLineStartsStatement(t *testing.T) {
+	is := is.New(t)
+	l := lexer.NewLexer(strings.NewReader("a = b\n[x, y] = pair"))
+	p := New(&l)
+	actual := p.ParseProgram()
+	is.Equal(len(p.Errors()), 0)
+	is.Equal(len(actual.Statements), 2)
+	_, ok := actual.Statements[1].(*ast.PatternAssignmentStatement)
+	is.True(ok)
+}
+
+func TestGeneralAssignment(t *testing.T) {
+	tests := []struct {
+		input     string
+		tokenType tokens.TokenType
+		operator  string
+		expected  string
+	}{
+		{"xs[0] = 1", tokens.ASSIGN, "", "xs[0] = 1"},
+		{`dict["k"] = v`, tokens.ASSIGN, "", `dict["k"] = v`},
+		{"grid[x][y] = 0", tokens.ASSIGN, "", "grid[x][y] = 0"},
+		{"count += 1", tokens.ADD_ASSIGN, "+", "count += 1"},
+		{"count -= 1", tokens.SUB_ASSIGN, "-", "count -= 1"},
+		{"total *= 2 + 1", tokens.ASTERISK_ASSIGN, "*", "total *= 2+1"},
+		{"half /= 2", tokens.SLASH_ASSIGN, "/", "half /= 2"},
+		{"xs[j] %= 3", tokens.PERCENT_ASSIGN, "%", "xs[j] %= 3"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			actual := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				for _, e := range p.Errors() {
+					t.Error(e)
+				}
+				t.Fatalf("got parser errors")
+			}
+			is.Equal(len(actual.Statements), 1)
+			stmt, ok := actual.Statements[0].(*ast.AssignmentStatement)
+			is.True(ok)
+			is.Equal(stmt.Token.Type, tt.tokenType)
+			is.Equal(stmt.Operator, tt.operator)
+			is.True(stmt.Left() != nil)
+			is.Equal(stmt.String(), tt.expected)
+		})
+	}
+}
+
+func TestNonAssignableTargets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 = 3", "cannot assign to 1+2, expected an identifier, index or selector expression (line: 0 col: 6)"},
+		{"-x += 1", "cannot assign to -x, expected an identifier, index or selector expression (line: 0 col: 3)"},
+		{"[a, b] += 1", "cannot assign to [a, b], expected an identifier, index or selector expression (line: 0 col: 7)"},
+		{"1 = 2", "cannot assign to 1, expected an identifier, index or selector expression (line: 0 col: 2)"},
+		{"{x + 1: a} = m", "cannot use x+1 as a map pattern key (line: 0 col: 1)"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			p.ParseProgram()
+			is.True(len(p.Errors()) > 0)
+			is.Equal(p.Errors()[0].String(), tt.expected)
+		})
+	}
+}
+
+func TestCallAndGroupedExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"add(1, 2 * 3)", "add(1, 2*3)"},
+		{"noop()", "noop()"},
+		{"(1 + 2) * 3", "1+2*3"},
+		{"fns[0](x)(y)", "fns[0](x)(y)"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			actual := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				for _, e := range p.Errors() {
+					t.Error(e)
+				}
+				t.Fatalf("got parser errors")
+			}
+			is.Equal(len(actual.Statements), 1)
+			is.Equal(actual.String(), tt.expected)
+		})
+	}
+	is := is.New(t)
+	l := lexer.NewLexer(strings.NewReader("(1 + 2) * 3"))
+	p := New(&l)
+	actual := p.ParseProgram()
+	exp := actual.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	is.Equal(exp.Operator, "*")
+	is.Equal(exp.Left.(*ast.InfixExpression).Operator, "+")
+}
+
+func TestPipeExpression(t *testing.T) {
+	is := is.New(t)
+	l := lexer.NewLexer(strings.NewReader("data |> filter(p) |> map(g) |> sum()"))
+	p := New(&l)
+	actual := p.ParseProgram()
+	is.Equal(len(p.Errors()), 0)
+	is.Equal(len(actual.Statements), 1)
+	stmt := actual.Statements[0].(*ast.ExpressionStatement)
+	pipe, ok := stmt.Expression.(*ast.PipeExpression)
+	is.True(ok)
+	is.Equal(pipe.Right.String(), "sum()")
+	pipe, ok = pipe.Left.(*ast.PipeExpression)
+	is.True(ok)
+	is.Equal(pipe.Right.String(), "map(g)")
+	pipe, ok = pipe.Left.(*ast.PipeExpression)
+	is.True(ok)
+	is.Equal(pipe.Left.String(), "data")
+	call, ok := pipe.Right.(*ast.CallExpression)
+	is.True(ok)
+	is.Equal(call.Function.String(), "filter")
+	is.Equal(len(call.Arguments), 1)
+	is.Equal(stmt.String(), "data |> filter(p) |> map(g) |> sum()")
+}
+
+func TestPipePrecedence(t *testing.T) {
+	tests := []struct {
+		input string
+		left  string
+	}{
+		{"a + 1 |> g()", "a+1"},
+		{"x == y |> g", "x==y"},
+		{"-x |> g(1)", "-x"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			actual := p.ParseProgram()
+			is.Equal(len(p.Errors()), 0)
+			pipe, ok := actual.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.PipeExpression)
+			is.True(ok)
+			is.Equal(pipe.Left.String(), tt.left)
+		})
+	}
+}
+
+func TestPipeIntoExpressions(t *testing.T) {
+	tests := []struct {
+		input, right string
+	}{
+		{"x |> fns[0]", "fns[0]"},
+		{"x |> obj.g", "obj.g"},
+		{"x |> f(a) { a }", "f(a) { a }"},
+		{"x |> 1 + 2", "1+2"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			actual := p.ParseProgram()
+			is.Equal(len(p.Errors()), 0)
+			pipe, ok := actual.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.PipeExpression)
+			is.True(ok)
+			is.Equal(pipe.Right.String(), tt.right)
+		})
+	}
+}
+
+func TestPipeErrors(t *testing.T) {
+	is := is.New(t)
+	l := lexer.NewLexer(strings.NewReader("x |>"))
+	p := New(&l)
+	p.ParseProgram()
+	is.True(len(p.Errors()) > 0)
+}
+
+func TestRangeExpressions(t *testing.T) {
+	tests := []struct {
+		input      string
+		start, end string
+		inclusive  bool
+	}{
+		{"1..10", "1", "10", false},
+		{"1..=10", "1", "10", true},
+		{"0..n + 1", "0", "n+1", false},
+		{"a * 2..=b", "a*2", "b", true},
+		{"..3", "", "3", false},
+		{"..=3", "", "3", true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			actual := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				for _, e := range p.Errors() {
+					t.Error(e)
+				}
+				t.Fatalf("got parser errors")
+			}
+			is.Equal(len(actual.Statements), 1)
+			exp, ok := actual.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.RangeExpression)
+			is.True(ok)
+			if tt.start == "" {
+				is.Equal(exp.Low, nil)
+			} else {
+				is.Equal(exp.Low.String(), tt.start)
+			}
+			is.Equal(exp.High.String(), tt.end)
+			is.Equal(exp.Inclusive, tt.inclusive)
+		})
+	}
+}
+
+func TestRangeSlicing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"xs[1..3]", "xs[1..3]"},
+		{"xs[1..=3]", "xs[1..=3]"},
+		{"xs[..3]", "xs[..3]"},
+		{"xs[..=n]", "xs[..=n]"},
+		{"xs[2..]", "xs[2..]"},
+		{"xs[..]", "xs[..]"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			actual := p.ParseProgram()
+			is.Equal(len(p.Errors()), 0)
+			exp, ok := actual.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression)
+			is.True(ok)
+			_, ok = exp.Index.(*ast.RangeExpression)
+			is.True(ok)
+			is.Equal(exp.String(), tt.expected)
+		})
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `l x in 1..=10 {
+	total += x
+	xs[x] = total
+}`
+	is := is.New(t)
+	l := lexer.NewLexer(strings.NewReader(input))
+	p := New(&l)
+	actual := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, e := range p.Errors() {
+			t.Error(e)
+		}
+		t.Fatalf("got parser errors")
+	}
+	is.Equal(len(actual.Statements), 1)
+	stmt, ok := actual.Statements[0].(*ast.ForStatement)
+	is.True(ok)
+	is.Equal(stmt.Variable.Value, "x")
+	_, ok = stmt.Iterable.(*ast.RangeExpression)
+	is.True(ok)
+	is.Equal(len(stmt.Body.Statements), 2)
+	is.Equal(stmt.String(), "l x in 1..=10 { total += x; xs[x] = total }")
+}
+
+func TestRangeAndForErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"xs[1..=]", "inclusive range must have an end (line: 0 col: 4)"},
+		{"l 1 in xs {}", "expected next token ident, got number (line: 0 col: 2)"},
+		{"l x xs {}", "expected next token in, got ident (line: 0 col: 4)"},
+		{"l x in xs { x", "expected } to close block, got EOF (line: 0 col: 13)"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			p.ParseProgram()
+			is.True(len(p.Errors()) > 0)
+			is.Equal(p.Errors()[0].String(), tt.expected)
+		})
+	}
+}
+
+func TestFunctionLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		params   []string
+		expected string
+	}{
+		{"f() { 1 }", []string{}, "f() { 1 }"},
+		{"f(a, b) { a + b }", []string{"a", "b"}, "f(a, b) { a+b }"},
+		{"f(a: int, b: int) -> int { a + b }", []string{"a: int", "b: int"}, "f(a: int, b: int) -> int { a+b }"},
+		{"f(a, b: str) { b }", []string{"a", "b: str"}, "f(a, b: str) { b }"},
+		{"f(xs: [int]) -> int? { xs[0] }", []string{"xs: [int]"}, "f(xs: [int]) -> int? { xs[0] }"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			actual := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				for _, e := range p.Errors() {
+					t.Error(e)
+				}
+				t.Fatalf("got parser errors")
+			}
+			is.Equal(len(actual.Statements), 1)
+			fn, ok := actual.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+			is.True(ok)
+			is.Equal(len(fn.Parameters), len(tt.params))
+			for i, param := range fn.Parameters {
+				is.Equal(param.String(), tt.params[i])
+			}
+			is.Equal(fn.String(), tt.expected)
+		})
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		typeNode ast.TypeExpr
+		expected string
+	}{
+		{`x: str = "hi"`, &ast.NamedType{}, "str"},
+		{"xs: [int] = []", &ast.ListType{}, "[int]"},
+		{"ages: {str: int} = {}", &ast.MapType{}, "{str: int}"},
+		{"op: f(int, int) -> int = add", &ast.FunctionType{}, "f(int, int) -> int"},
+		{"cb: f() = noop", &ast.FunctionType{}, "f()"},
+		{"name: str? = n", &ast.OptionalType{}, "str?"},
+		{"grid: [[int?]]? = g", &ast.OptionalType{}, "[[int?]]?"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			actual := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				for _, e := range p.Errors() {
+					t.Error(e)
+				}
+				t.Fatalf("got parser errors")
+			}
+			is.Equal(len(actual.Statements), 1)
+			stmt, ok := actual.Statements[0].(*ast.AssignmentStatement)
+			is.True(ok)
+			is.Equal(fmt.Sprintf("%T", stmt.Type), fmt.Sprintf("%T", tt.typeNode))
+			is.Equal(stmt.Type.String(), tt.expected)
+		})
+	}
+}
+
+func TestUntypedAssignmentUnchanged(t *testing.T) {
+	is := is.New(t)
+	l := lexer.NewLexer(strings.NewReader("add = f(a, b) { a + b }"))
+	p := New(&l)
+	actual := p.ParseProgram()
+	is.Equal(len(p.Errors()), 0)
+	stmt, ok := actual.Statements[0].(*ast.AssignmentStatement)
+	is.True(ok)
+	is.Equal(stmt.Type, nil)
+	fn := stmt.Value.(*ast.FunctionLiteral)
+	is.Equal(fn.ReturnType, nil)
+	for _, param := range fn.Parameters {
+		is.Equal(param.Type, nil)
+	}
+}
+
+func TestTypeAnnotationErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x: 1 = 1", "expected type, got number (line: 0 col: 3)"},
+		{"x: int 1", "expected next token =, got number (line: 0 col: 7)"},
+		{"f(a: ) { a }", "expected type, got ) (line: 0 col: 5)"},
+		{"f(a b) { a }", "expected next token ,, got ident (line: 0 col: 4)"},
+		{"f(a) -> { a }", "expected next token :, got } (line: 0 col: 12)"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			p.ParseProgram()
+			is.True(len(p.Errors()) > 0)
+			is.Equal(p.Errors()[0].String(), tt.expected)
+		})
+	}
+}
+
+func TestTypeDeclaration(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		fields   []string
+		expected string
+	}{
+		{"t Point { x, y }", "Point", []string{"x", "y"}, "t Point { x, y }"},
+		{"t Point { x: int, y: int, }", "Point", []string{"x: int", "y: int"}, "t Point { x: int, y: int }"},
+		{"t Empty {}", "Empty", []string{}, "t Empty {  }"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			actual := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				for _, e := range p.Errors() {
+					t.Error(e)
+				}
+				t.Fatalf("got parser errors")
+			}
+			is.Equal(len(actual.Statements), 1)
+			decl, ok := actual.Statements[0].(*ast.TypeDeclaration)
+			is.True(ok)
+			is.Equal(decl.Name.Value, tt.name)
+			is.Equal(len(decl.Fields), len(tt.fields))
+			for i, f := range decl.Fields {
+				is.Equal(f.String(), tt.fields[i])
+			}
+			is.Equal(decl.String(), tt.expected)
+		})
+	}
+}
+
+func TestRecordLiteralsAndSelectors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Point{x: 1, y: 2}", "Point{x: 1, y: 2}"},
+		{"Point{x, y: y + 1}", "Point{x, y: y+1}"},
+		{"geo.Point{}", "geo.Point{}"},
+		{"p.x", "p.x"},
+		{"a.b.c(1).d", "a.b.c(1).d"},
+		{"Point{x: 1}.x", "Point{x: 1}.x"},
+		{"xs[0].name", "xs[0].name"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			actual := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				for _, e := range p.Errors() {
+					t.Error(e)
+				}
+				t.Fatalf("got parser errors")
+			}
+			is.Equal(len(actual.Statements), 1)
+			is.Equal(actual.String(), tt.expected)
+		})
+	}
+}
+
+func TestSelectorChain(t *testing.T) {
+	is := is.New(t)
+	l := lexer.NewLexer(strings.NewReader("a.b.c(1).d"))
+	p := New(&l)
+	actual := p.ParseProgram()
+	is.Equal(len(p.Errors()), 0)
+	d, ok := actual.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.SelectorExpression)
+	is.True(ok)
+	is.Equal(d.Sel.Value, "d")
+	call, ok := d.X.(*ast.CallExpression)
+	is.True(ok)
+	is.Equal(len(call.Arguments), 1)
+	c, ok := call.Function.(*ast.SelectorExpression)
+	is.True(ok)
+	is.Equal(c.Sel.Value, "c")
+	b, ok := c.X.(*ast.SelectorExpression)
+	is.True(ok)
+	is.Equal(b.Sel.Value, "b")
+	a, ok := b.X.(*ast.Identifier)
+	is.True(ok)
+	is.Equal(a.Value, "a")
+}
+
+func TestRecordLiteralsAfterBlockHeaders(t *testing.T) {
+	tests := []struct {
+		input string
+		check func(is *is.I, stmt ast.Statement)
+	}{
+		{"m p { _ -> 1 }", func(is *is.I, stmt ast.Statement) {
+			me, ok := stmt.(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+			is.True(ok)
+			is.Equal(me.Subject.String(), "p")
+		}},
+		{"m p { _ -> Point{x: 1} }", func(is *is.I, stmt ast.Statement) {
+			me := stmt.(*ast.ExpressionStatement).Expression.(*ast.MatchExpression)
+			_, ok := me.Arms[0].Body.(*ast.RecordLiteral)
+			is.True(ok)
+		}},
+		{"l x in xs { ys = Point{x} }", func(is *is.I, stmt ast.Statement) {
+			fs := stmt.(*ast.ForStatement)
+			is.Equal(fs.Iterable.String(), "xs")
+			_, ok := fs.Body.Statements[0].(*ast.AssignmentStatement).Value.(*ast.RecordLiteral)
+			is.True(ok)
+		}},
+		{"l x in g(Point{y}) { x }", func(is *is.I, stmt ast.Statement) {
+			_, ok := stmt.(*ast.ForStatement).Iterable.(*ast.CallExpression)
+			is.True(ok)
+		}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			actual := p.ParseProgram()
+			if len(p.Errors()) != 0 {
+				for _, e := range p.Errors() {
+					t.Error(e)
+				}
+				t.Fatalf("got parser errors")
+			}
+			is.Equal(len(actual.Statements), 1)
+			tt.check(is, actual.Statements[0])
+		})
+	}
+}
+
+func TestFieldAssignment(t *testing.T) {
+	is := is.New(t)
+	l := lexer.NewLexer(strings.NewReader("p.x = 1\np.pos.y += 2"))
+	p := New(&l)
+	actual := p.ParseProgram()
+	is.Equal(len(p.Errors()), 0)
+	is.Equal(len(actual.Statements), 2)
+	for _, stmt := range actual.Statements {
+		as, ok := stmt.(*ast.AssignmentStatement)
+		is.True(ok)
+		_, ok = as.Target.(*ast.SelectorExpression)
+		is.True(ok)
+	}
+}
+
+func TestRecordErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"t Point { x, x }", "duplicate field x in type Point (line: 0 col: 13)"},
+		{"t { x }", "expected next token ident, got { (line: 0 col: 2)"},
+		{"1{x: 1}", "1 is not a type name (line: 0 col: 1)"},
+		{"p.1", "expected next token ident, got number (line: 0 col: 2)"},
+		{`Point{"x": 1}`, "expected next token ident, got string (line: 0 col: 6)"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			p.ParseProgram()
+			is.True(len(p.Errors()) > 0)
+			is.Equal(p.Errors()[0].String(), tt.expected)
+		})
+	}
+}
+
+func TestRecordLiteralOfBadType(t *testing.T) {
+	is := is.New(t)
+	l := lexer.NewLexer(strings.NewReader("(){}"))
+	p := New(&l)
+	p.ParseProgram()
+	is.True(len(p.Errors()) > 0)
+	is.Equal(p.Errors()[0].String(), "prefix ) not recognised (line: 0 col: 1)")
+	for _, err := range p.Errors() {
+		is.True(!strings.Contains(err.String(), "is not a type name")) // the bad type is only reported once
+	}
+}
+
+func TestImportStatement(t *testing.T) {
+	tests := []struct {
+		input     string
+		path      string
+		localName string
+	}{
+		{`im "path/to/lib"`, "path/to/lib", "lib"},
+		{`im util "path/to/lib"`, "path/to/lib", "util"},
+		{`im "helpers.brev"`, "helpers.brev", "helpers"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			actual := p.ParseProgram()
+			is.Equal(len(p.Errors()), 0)
+			is.Equal(len(actual.Statements), 1)
+			stmt, ok := actual.Statements[0].(*ast.ImportStatement)
+			is.True(ok)
+			is.Equal(stmt.Path.Value, tt.path)
+			is.Equal(stmt.LocalName(), tt.localName)
+			is.Equal(stmt.String(), tt.input)
+		})
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"im lib", "expected next token string, got EOF (line: 0 col: 6)"},
+		{`im ""`, "import path must not be empty (line: 0 col: 3)"},
+		{"a = 1\nim \"lib\"", "imports must come before other statements (line: 1 col: 0)"},
+		{"g = f() {\n\tim \"lib\"\n}", "imports must be at the top level of a file (line: 1 col: 1)"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			l := lexer.NewLexer(strings.NewReader(tt.input))
+			p := New(&l)
+			p.ParseProgram()
+			is.True(len(p.Errors()) > 0)
+			is.Equal(p.Errors()[0].String(), tt.expected)
+		})
+	}
+}
+
+func TestParseFiles(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.brev")
+	lib := filepath.Join(dir, "lib.brev")
+	is.NoErr(os.WriteFile(main, []byte("im \"lib\"\n\nx = lib.double(2)\n"), 0o644))
+	is.NoErr(os.WriteFile(lib, []byte("double = f(n) { n * 2 }\n"), 0o644))
+
+	pkg, err := ParseFiles([]string{main, lib})
+	is.NoErr(err)
+	is.Equal(len(pkg.Files), 2)
+	is.Equal(pkg.Files[0].Filename, main)
+	is.Equal(len(pkg.Files[0].Imports()), 1)
+	is.Equal(pkg.Files[1].Filename, lib)
+
+	assign := pkg.Files[0].Statements[1].(*ast.AssignmentStatement)
+	is.Equal(assign.Name.Token, tokens.Token{Type: tokens.IDENT, Literal: "x", Row: 2, Col: 0, File: main})
+	assign = pkg.Files[1].Statements[0].(*ast.AssignmentStatement)
+	is.Equal(assign.Name.Token, tokens.Token{Type: tokens.IDENT, Literal: "double", Row: 0, Col: 0, File: lib})
+}
+
+func TestParseFilesOptions(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.brev")
+	b := filepath.Join(dir, "b.brev")
+	is.NoErr(os.WriteFile(a, []byte("// a\nx = 1 pow 2\n"), 0o644))
+	is.NoErr(os.WriteFile(b, []byte("y = 2 pow 3 // b\n"), 0o644))
+
+	pkg, err := ParseFiles([]string{a, b}, WithComments(), WithInfix("pow", PRODUCT+1, RightAssoc))
+	is.NoErr(err)
+	for i, text := range []string{"a", "b"} {
+		program := pkg.Files[i]
+		is.Equal(len(program.Comments), 1)
+		is.Equal(program.Comments[0].Text(), text)
+		value := program.Statements[0].(*ast.AssignmentStatement).Value
+		is.Equal(value.(*ast.InfixExpression).Operator, "pow")
+	}
+}
+
+func TestParseFilesErrors(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.brev")
+	b := filepath.Join(dir, "b.brev")
+	is.NoErr(os.WriteFile(a, []byte("x = )"), 0o644))
+	is.NoErr(os.WriteFile(b, []byte("\ny = ]"), 0o644))
+
+	_, err := ParseFiles([]string{a, b})
+	var errs ErrorList
+	is.True(errors.As(err, &errs))
+	is.Equal(len(errs), 2)
+	is.Equal(errs[0].Token.File, a)
+	is.Equal(errs[1].Token.File, b)
+	is.Equal(errs[1].String(), fmt.Sprintf("prefix ] not recognised (file: %s line: 1 col: 4)", b))
+
+	_, err = ParseFiles([]string{filepath.Join(dir, "missing.brev")})
+	is.True(errors.Is(err, os.ErrNotExist))
+}
+
+func TestParseString(t *testing.T) {
+	is := is.New(t)
+	program, err := ParseString("a = 1\nb = a + 1")
+	is.NoErr(err)
+	is.Equal(len(program.Statements), 2)
+	is.Equal(program.Filename, "")
+
+	program, err = ParseString("a = )")
+	var errs ErrorList
+	is.True(errors.As(err, &errs))
+	is.Equal(len(errs), 1)
+	is.Equal(err.Error(), "prefix ) not recognised (line: 0 col: 4)")
+	is.True(program != nil)
+}
+
+func TestParseFile(t *testing.T) {
+	is := is.New(t)
+	path := filepath.Join(t.TempDir(), "prog.brev")
+	is.NoErr(os.WriteFile(path, []byte("// double it\nx = 2 * y\n"), 0o644))
+
+	program, err := ParseFile(path)
+	is.NoErr(err)
+	is.Equal(program.Filename, path)
+	is.Equal(len(program.Comments), 0)
+	stmt := program.Statements[0].(*ast.AssignmentStatement)
+	is.Equal(stmt.Token.File, path)
+	is.Equal(stmt.Token.Row, 1)
+
+	program, err = ParseFile(path, WithComments())
+	is.NoErr(err)
+	is.Equal(len(program.Comments), 1)
+	is.Equal(program.Comments[0].Text(), "double it")
+	is.Equal(program.Comments[0].List[0].Token.File, path)
+
+	_, err = ParseFile(filepath.Join(t.TempDir(), "missing.brev"))
+	is.True(errors.Is(err, os.ErrNotExist))
+}
+
+func TestParseExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"1 + 2 * x", ""},
+		{"m v { _ -> 1 }", ""},
+		{"a = 1", "expected a single expression (line: 0 col: 0)"},
+		{"1\n  a = 2", "expected a single expression (line: 1 col: 2)"},
+		{"1 + 2\n3", "expected a single expression (line: 1 col: 0)"},
+		{"", "expected a single expression (line: 0 col: 0)"},
+		{"  // nothing", "expected a single expression (line: 0 col: 12)"},
+		{"1 +", "prefix EOF not recognised (line: 0 col: 3)"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			program, err := ParseExpr(tt.input)
+			if tt.err == "" {
+				is.NoErr(err)
+				is.Equal(len(program.Statements), 1)
+				return
+			}
+			is.True(err != nil)
+			is.Equal(err.Error(), tt.err)
+		})
+	}
+}
+
+func TestTrace(t *testing.T) {
+	is := is.New(t)
+	var buf strings.Builder
+	_, err := ParseString("-x", WithTrace(&buf))
+	is.NoErr(err)
+	expected := `BEGIN parseStatement (- "-")
+	BEGIN parseExpressionStatement (- "-")
+		BEGIN parseExpression (- "-")
+			BEGIN parsePrefixExpression (- "-")
+				BEGIN parseExpression (ident "x")
+				END parseExpression
+			END parsePrefixExpression
+		END parseExpression
+	END parseExpressionStatement
+END parseStatement
+`
+	is.Equal(buf.String(), expected)
+}
+
+func TestCommentsAreSkipped(t *testing.T) {
+	is := is.New(t)
+	input := `// leading
+a = 1 // trailing
+// between
+b = a / 2 // division isn't a comment`
+	program, err := ParseString(input, WithComments())
+	is.NoErr(err)
+	is.Equal(len(program.Statements), 2)
+	is.Equal(program.String(), "a = 1b = a/2")
+	is.Equal(len(program.Comments), 4)
+	is.Equal(program.Comments[1].Pos().Row, 1)
+	is.Equal(program.Comments[1].Pos().Col, 6)
+}
+
+func TestCommentGroups(t *testing.T) {
+	is := is.New(t)
+	input := `// the file
+// doc
+
+// first
+// leads a
+a = 1 // trails a
+// leads b
+b = g(a) // trails b
+
+// alone
+
+c = f() { // not attached to c
+	// leads d
+	d
+	// before close
+}
+r = m c {
+	// leads arm
+	0 -> 1, // trails arm
+	_ -> 2,
+}`
+	program, err := ParseString(input, WithComments())
+	is.NoErr(err)
+	texts := make([]string, len(program.Comments))
+	for i, g := range program.Comments {
+		texts[i] = g.Text()
+	}
+	is.Equal(texts, []string{
+		"the file\ndoc", "first\nleads a", "trails a", "leads b", "trails b", "alone",
+		"not attached to c", "leads d", "before close", "leads arm", "trails arm",
+	})
+	is.Equal(program.Doc, program.Comments[0])
+
+	a, b := program.Statements[0], program.Statements[1]
+	is.Equal(program.CommentMap[a].Leading.Text(), "first\nleads a")
+	is.Equal(program.CommentMap[a].Trailing.Text(), "trails a")
+	is.Equal(program.CommentMap[b].Leading.Text(), "leads b")
+	is.Equal(program.CommentMap[b].Trailing.Text(), "trails b")
+
+	body := program.Statements[2].(*ast.AssignmentStatement).Value.(*ast.FunctionLiteral).Body
+	is.Equal(program.CommentMap[body.Statements[0]].Leading.Text(), "leads d")
+
+	arm := program.Statements[3].(*ast.AssignmentStatement).Value.(*ast.MatchExpression).Arms[0]
+	is.Equal(program.CommentMap[arm].Leading.Text(), "leads arm")
+	is.Equal(program.CommentMap[arm].Trailing.Text(), "trails arm")
+
+	unattached := program.Unattached()
+	texts = texts[:0]
+	for _, g := range unattached {
+		texts = append(texts, g.Text())
+	}
+	is.Equal(texts, []string{"alone", "not attached to c", "before close"})
+}
+
+func TestCommentDoc(t *testing.T) {
+	tests := []struct {
+		input string
+		doc   string
+	}{
+		{"// doc\n\na = 1", "doc"},
+		{"// leads a\na = 1", ""},
+		{"// only comments", "only comments"},
+		{"a = 1\n\n// after", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			program, err := ParseString(tt.input, WithComments())
+			is.NoErr(err)
+			if tt.doc == "" {
+				is.Equal(program.Doc, nil)
+				return
+			}
+			is.Equal(program.Doc.Text(), tt.doc)
+		})
+	}
+}
+
+func TestIncompleteInput(t *testing.T) {
+	tests := []struct {
+		input      string
+		incomplete bool
+	}{
+		{"add = f(a, b) {", true},
+		{"add = f(a, b) {\n\ta + b", true},
+		{"x = add(1,", true},
+		{"(1 + 2", true},
+		{"1 +", true},
+		{"x =", true},
+		{"m v {\n\t0 -> 1,", true},
+		{"xs = [1, 2", true},
+		{`s = "abc`, true},
+		{"l x in 1..3 {", true},
+		{"t Point { x,", true},
+		{"x = )", false},
+		{"1 + }", false},
+		{") + (", false},
+		{"m v { 1 + -> 1 }", false},
+		{"1 + 2", false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			_, err := ParseString(tt.input)
+			if tt.input == "1 + 2" {
+				is.NoErr(err)
+			} else {
+				is.True(err != nil)
+			}
+			is.Equal(IsIncomplete(err), tt.incomplete)
+		})
+	}
+}
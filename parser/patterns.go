@@ -0,0 +1,313 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/joerdav/brev/ast"
+	"github.com/joerdav/brev/tokens"
+)
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	defer p.trace("parseMatchExpression")()
+	exp := &ast.MatchExpression{Token: p.curToken}
+	p.nextToken()
+	restore := p.allowRecordLit(false)
+	exp.Subject = p.parseExpression(LOWEST)
+	restore()
+	defer p.allowRecordLit(true)()
+	if !p.expectPeek(tokens.LBRC) {
+		return nil
+	}
+	for !p.peekTokenIs(tokens.RBRC) {
+		p.nextToken()
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		exp.Arms = append(exp.Arms, arm)
+		if !p.peekTokenIs(tokens.RBRC) && !p.expectPeek(tokens.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	exp.Rbrace = p.curToken
+	p.checkMatchArms(exp)
+	return exp
+}
+
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Token: p.curToken}
+	arm.Pattern = p.parsePattern()
+	if arm.Pattern == nil {
+		return nil
+	}
+	if p.peekTokenIs(tokens.IF) {
+		p.nextToken()
+		p.nextToken()
+		if arm.Guard = p.parseExpression(LOWEST); arm.Guard == nil {
+			return nil
+		}
+	}
+	if !p.expectPeek(tokens.ARROW) {
+		return nil
+	}
+	p.nextToken()
+	arm.Body = p.parseExpression(LOWEST)
+	if arm.Body == nil {
+		return nil
+	}
+	return arm
+}
+
+// checkMatchArms reports arms that can never be reached because an earlier
+// unguarded arm already matches everything they would.
+func (p *Parser) checkMatchArms(exp *ast.MatchExpression) {
+	var seen []ast.Pattern
+	for _, arm := range exp.Arms {
+		for _, prev := range seen {
+			if covers(prev, arm.Pattern) {
+				msg := fmt.Sprintf("unreachable match arm %s, already matched by %s", arm.Pattern, prev)
+				p.errors = append(p.errors, ParserError{Message: msg, Token: arm.Token})
+				break
+			}
+		}
+		if arm.Guard == nil {
+			seen = append(seen, arm.Pattern)
+		}
+	}
+}
+
+// covers reports whether every value matched by b is also matched by a.
+func covers(a, b ast.Pattern) bool {
+	switch a := a.(type) {
+	case *ast.WildcardPattern, *ast.BindingPattern:
+		return true
+	case *ast.LiteralPattern:
+		b, ok := b.(*ast.LiteralPattern)
+		return ok && a.String() == b.String()
+	case *ast.ArrayPattern:
+		b, ok := b.(*ast.ArrayPattern)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !covers(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *ast.MapPattern:
+		b, ok := b.(*ast.MapPattern)
+		if !ok {
+			return false
+		}
+	keys:
+		for _, ap := range a.Pairs {
+			for _, bp := range b.Pairs {
+				if ap.Key.String() == bp.Key.String() && covers(ap.Value, bp.Value) {
+					continue keys
+				}
+			}
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func (p *Parser) parsePattern() ast.Pattern {
+	defer p.trace("parsePattern")()
+	switch p.curToken.Type {
+	case tokens.IDENT:
+		if p.curToken.Literal == "_" {
+			return &ast.WildcardPattern{Token: p.curToken}
+		}
+		return &ast.BindingPattern{
+			Token: p.curToken,
+			Name:  &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+		}
+	case tokens.NUMBER, tokens.STRING, tokens.TRUE, tokens.FALSE:
+		pat := &ast.LiteralPattern{Token: p.curToken}
+		pat.Value = p.prefixParseFns[p.curToken.Type]()
+		if pat.Value == nil {
+			return nil
+		}
+		return pat
+	case tokens.SUB:
+		if !p.peekTokenIs(tokens.NUMBER) {
+			break
+		}
+		pat := &ast.LiteralPattern{Token: p.curToken}
+		pat.Value = p.parsePrefixExpression()
+		return pat
+	case tokens.LSQB:
+		return p.parseArrayPattern()
+	case tokens.LBRC:
+		return p.parseMapPattern()
+	}
+	msg := fmt.Sprintf("expected pattern, got %s", p.curToken.Type)
+	p.errors = append(p.errors, ParserError{Message: msg, Token: p.curToken})
+	return nil
+}
+
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pat := &ast.ArrayPattern{Token: p.curToken}
+	for !p.peekTokenIs(tokens.RSQB) {
+		p.nextToken()
+		elem := p.parsePattern()
+		if elem == nil {
+			return nil
+		}
+		pat.Elements = append(pat.Elements, elem)
+		if !p.peekTokenIs(tokens.RSQB) && !p.expectPeek(tokens.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	pat.Rbrack = p.curToken
+	return pat
+}
+
+func (p *Parser) parseMapPattern() ast.Pattern {
+	pat := &ast.MapPattern{Token: p.curToken}
+	for !p.peekTokenIs(tokens.RBRC) {
+		p.nextToken()
+		pair := &ast.MapPatternPair{Token: p.curToken}
+		switch {
+		case p.curTokenIs(tokens.IDENT) && !p.peekTokenIs(tokens.COLON):
+			pair.Key = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+			pair.Value = p.parsePattern()
+			pair.Shorthand = true
+		case p.curTokenIs(tokens.STRING), p.curTokenIs(tokens.NUMBER),
+			p.curTokenIs(tokens.TRUE), p.curTokenIs(tokens.FALSE):
+			if pair.Key = p.prefixParseFns[p.curToken.Type](); pair.Key == nil {
+				return nil
+			}
+			if !p.expectPeek(tokens.COLON) {
+				return nil
+			}
+			p.nextToken()
+			pair.Value = p.parsePattern()
+		default:
+			msg := fmt.Sprintf("expected map pattern key, got %s", p.curToken.Type)
+			p.errors = append(p.errors, ParserError{Message: msg, Token: p.curToken})
+			return nil
+		}
+		if pair.Value == nil {
+			return nil
+		}
+		pat.Pairs = append(pat.Pairs, pair)
+		if !p.peekTokenIs(tokens.RBRC) && !p.expectPeek(tokens.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	pat.Rbrace = p.curToken
+	return pat
+}
+
+// parsePatternAssignment parses a destructuring assignment, first is the
+// already parsed first target.
+func (p *Parser) parsePatternAssignment(first ast.Expression) ast.Statement {
+	defer p.trace("parsePatternAssignment")()
+	stmt := &ast.PatternAssignmentStatement{}
+	for exp := first; ; {
+		target := p.exprToPattern(exp)
+		if target == nil {
+			return nil
+		}
+		stmt.Targets = append(stmt.Targets, target)
+		if !p.peekTokenIs(tokens.COMMA) {
+			break
+		}
+		p.nextToken()
+		p.nextToken()
+		exp = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(tokens.ASSIGN) {
+		return nil
+	}
+	stmt.Token = p.curToken
+	for {
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		if value == nil {
+			return nil
+		}
+		stmt.Values = append(stmt.Values, value)
+		if !p.peekTokenIs(tokens.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if len(stmt.Targets) != len(stmt.Values) {
+		msg := fmt.Sprintf("assignment mismatch: %d targets but %d values", len(stmt.Targets), len(stmt.Values))
+		p.errors = append(p.errors, ParserError{Message: msg, Token: stmt.Token})
+		return nil
+	}
+	return stmt
+}
+
+// exprToPattern converts an expression parsed on the left of an assignment
+// into the pattern it describes. Literals are rejected, they may fail to match
+// so can only be used in a match arm.
+func (p *Parser) exprToPattern(exp ast.Expression) ast.Pattern {
+	switch exp := exp.(type) {
+	case nil:
+		return nil
+	case *ast.Identifier:
+		if exp.Value == "_" {
+			return &ast.WildcardPattern{Token: exp.Token}
+		}
+		return &ast.BindingPattern{Token: exp.Token, Name: exp}
+	case *ast.ArrayLiteral:
+		pat := &ast.ArrayPattern{Token: exp.Token, Rbrack: exp.Rbrack}
+		for _, e := range exp.Elements {
+			elem := p.exprToPattern(e)
+			if elem == nil {
+				return nil
+			}
+			pat.Elements = append(pat.Elements, elem)
+		}
+		return pat
+	case *ast.MapLiteral:
+		pat := &ast.MapPattern{Token: exp.Token, Rbrace: exp.Rbrace}
+		for _, pair := range exp.Pairs {
+			switch pair.Key.(type) {
+			case *ast.StringLiteral, *ast.IntLiteral, *ast.Boolean:
+			default:
+				msg := fmt.Sprintf("cannot use %s as a map pattern key", pair.Key)
+				p.errors = append(p.errors, ParserError{Message: msg, Token: pair.Token})
+				return nil
+			}
+			value := p.exprToPattern(pair.Value)
+			if value == nil {
+				return nil
+			}
+			pat.Pairs = append(pat.Pairs, &ast.MapPatternPair{
+				Token:     pair.Token,
+				Key:       pair.Key,
+				Value:     value,
+				Shorthand: pair.Shorthand,
+			})
+		}
+		return pat
+	case *ast.IntLiteral:
+		p.literalTargetError(exp, exp.Token)
+		return nil
+	case *ast.StringLiteral:
+		p.literalTargetError(exp, exp.Token)
+		return nil
+	case *ast.Boolean:
+		p.literalTargetError(exp, exp.Token)
+		return nil
+	}
+	msg := fmt.Sprintf("cannot assign to %s", exp)
+	p.errors = append(p.errors, ParserError{Message: msg, Token: p.curToken})
+	return nil
+}
+
+func (p *Parser) literalTargetError(lit ast.Expression, t tokens.Token) {
+	msg := fmt.Sprintf("cannot assign to literal %s", lit)
+	p.errors = append(p.errors, ParserError{Message: msg, Token: t})
+}
@@ -0,0 +1,26 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// trace writes the start of the named parse function when tracing is
+// enabled, it returns a function that writes the end.
+//
+//	defer p.trace("parseExpression")()
+func (p *Parser) trace(name string) func() {
+	if p.tracer == nil {
+		return func() {}
+	}
+	p.tracef("BEGIN %s (%s %q)", name, p.curToken.Type, p.curToken.Literal)
+	p.traceDepth++
+	return func() {
+		p.traceDepth--
+		p.tracef("END %s", name)
+	}
+}
+
+func (p *Parser) tracef(format string, args ...interface{}) {
+	fmt.Fprintf(p.tracer, "%s%s\n", strings.Repeat("\t", p.traceDepth), fmt.Sprintf(format, args...))
+}
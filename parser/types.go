@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/joerdav/brev/ast"
+	"github.com/joerdav/brev/tokens"
+)
+
+// parseType parses a type annotation starting at the current token, leaving
+// the last token of the type as the current token.
+func (p *Parser) parseType() ast.TypeExpr {
+	defer p.trace("parseType")()
+	var typ ast.TypeExpr
+	switch p.curToken.Type {
+	case tokens.IDENT:
+		typ = &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+	case tokens.LSQB:
+		lt := &ast.ListType{Token: p.curToken}
+		p.nextToken()
+		if lt.Elem = p.parseType(); lt.Elem == nil || !p.expectPeek(tokens.RSQB) {
+			return nil
+		}
+		lt.Rbrack = p.curToken
+		typ = lt
+	case tokens.LBRC:
+		mt := &ast.MapType{Token: p.curToken}
+		p.nextToken()
+		if mt.Key = p.parseType(); mt.Key == nil || !p.expectPeek(tokens.COLON) {
+			return nil
+		}
+		p.nextToken()
+		if mt.Value = p.parseType(); mt.Value == nil || !p.expectPeek(tokens.RBRC) {
+			return nil
+		}
+		mt.Rbrace = p.curToken
+		typ = mt
+	case tokens.FUNCTION:
+		if typ = p.parseFunctionType(); typ == nil {
+			return nil
+		}
+	default:
+		msg := fmt.Sprintf("expected type, got %s", p.curToken.Type)
+		p.errors = append(p.errors, ParserError{Message: msg, Token: p.curToken})
+		return nil
+	}
+	for p.peekTokenIs(tokens.QUESTION) {
+		p.nextToken()
+		typ = &ast.OptionalType{Token: p.curToken, Elem: typ}
+	}
+	return typ
+}
+
+func (p *Parser) parseFunctionType() ast.TypeExpr {
+	ft := &ast.FunctionType{Token: p.curToken, Params: []ast.TypeExpr{}}
+	if !p.expectPeek(tokens.LBRK) {
+		return nil
+	}
+	for !p.peekTokenIs(tokens.RBRK) {
+		p.nextToken()
+		param := p.parseType()
+		if param == nil {
+			return nil
+		}
+		ft.Params = append(ft.Params, param)
+		if !p.peekTokenIs(tokens.RBRK) && !p.expectPeek(tokens.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	ft.Rparen = p.curToken
+	if p.peekTokenIs(tokens.ARROW) {
+		p.nextToken()
+		p.nextToken()
+		if ft.Result = p.parseType(); ft.Result == nil {
+			return nil
+		}
+	}
+	return ft
+}
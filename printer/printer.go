@@ -0,0 +1,642 @@
+// Package printer prints Brev syntax trees as canonical source.
+//
+// The output is parseable and stable: printing a program, parsing the result
+// and printing it again gives the same source. Statements are one per line,
+// blocks and match arms are indented with tabs, binary operators are spaced
+// and parentheses are only kept where precedence needs them. Single blank
+// lines between statements are kept, as are comments when the program was
+// parsed with parser.WithComments. Operators added to the grammar with
+// parser.WithInfix are printed by passing the same options to the printer.
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/joerdav/brev/ast"
+	"github.com/joerdav/brev/lexer"
+	"github.com/joerdav/brev/parser"
+	"github.com/joerdav/brev/tokens"
+)
+
+// highest is the precedence of operands that never need parentheses.
+const highest = parser.INDEX + 1
+
+// Fprint writes the canonical source for node to w. A program is printed
+// with a trailing newline and its comments, any other node is printed on its
+// own without comments. opts are the options node was parsed with, they give
+// the precedence of operators added to the grammar.
+func Fprint(w io.Writer, node ast.Node, opts ...parser.Option) error {
+	l := lexer.NewLexer(strings.NewReader(""))
+	p := &printer{
+		grammar: parser.New(&l, opts...),
+		lastRow: -1,
+		closer:  tokens.Position{Row: math.MaxInt32},
+	}
+	if err := p.node(node); err != nil {
+		return err
+	}
+	_, err := w.Write(p.out.Bytes())
+	return err
+}
+
+// Source parses src with opts, comments included, and returns it in
+// canonical form. The error is a parser.ErrorList if src has syntax errors.
+func Source(src []byte, opts ...parser.Option) ([]byte, error) {
+	program, err := parser.ParseString(string(src), append([]parser.Option{parser.WithComments()}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := Fprint(&buf, program, opts...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type printer struct {
+	// grammar is a parser with the options the tree was parsed with, asked
+	// for the precedence of operators.
+	grammar   *parser.Parser
+	out       bytes.Buffer
+	indent    int
+	lineStart bool
+	// comments are the comments still to be printed, in source order.
+	comments []*ast.Comment
+	// lastRow is the source row of the last statement or comment printed,
+	// -1 at the start of a file or block.
+	lastRow int
+	// closer is the position of the brace closing the block or match being
+	// printed, comments after it don't trail the statements inside.
+	closer tokens.Position
+}
+
+func (p *printer) node(node ast.Node) error {
+	switch n := node.(type) {
+	case *ast.Program:
+		for _, g := range n.Comments {
+			p.comments = append(p.comments, g.List...)
+		}
+		p.stmts(n.Statements)
+		p.commentsBefore(p.closer)
+	case ast.Statement:
+		p.stmt(n)
+	case ast.Expression:
+		p.expr(n, parser.LOWEST)
+	case ast.Pattern:
+		p.pattern(n)
+	case ast.TypeExpr:
+		p.typ(n)
+	case *ast.Comment:
+		p.write(strings.TrimRight(n.Token.Literal, " \t\r"))
+	case *ast.CommentGroup:
+		for i, c := range n.List {
+			if i > 0 {
+				p.newline()
+			}
+			p.write(strings.TrimRight(c.Token.Literal, " \t\r"))
+		}
+	default:
+		return fmt.Errorf("printer: unsupported node type %T", node)
+	}
+	return nil
+}
+
+func (p *printer) write(s string) {
+	if p.lineStart {
+		p.out.WriteString(strings.Repeat("\t", p.indent))
+		p.lineStart = false
+	}
+	p.out.WriteString(s)
+}
+
+func (p *printer) newline() {
+	p.out.WriteByte('\n')
+	p.lineStart = true
+}
+
+// separate keeps a single blank line before source row if there was at least
+// one in the source.
+func (p *printer) separate(row int) {
+	if p.lastRow >= 0 && row > p.lastRow+1 {
+		p.out.WriteByte('\n')
+	}
+}
+
+// commentsBefore prints, one per line, the pending comments that come before
+// pos in the source.
+func (p *printer) commentsBefore(pos tokens.Position) {
+	for p.hasCommentsBefore(pos) {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		p.separate(c.Token.Row)
+		p.write(strings.TrimRight(c.Token.Literal, " \t\r"))
+		p.newline()
+		p.lastRow = c.Token.Row
+	}
+}
+
+// trailingComment prints the next comment at the end of the current line if
+// it is on source row.
+func (p *printer) trailingComment(row int) {
+	if len(p.comments) > 0 && p.comments[0].Token.Row == row && p.hasCommentsBefore(p.closer) {
+		p.write(" " + strings.TrimRight(p.comments[0].Token.Literal, " \t\r"))
+		p.comments = p.comments[1:]
+	}
+}
+
+func (p *printer) stmts(list []ast.Statement) {
+	for _, s := range list {
+		start, end := s.Pos(), s.End()
+		if hasPos(end) {
+			p.commentsBefore(start)
+			p.separate(start.Row)
+		}
+		p.stmt(s)
+		if hasPos(end) {
+			p.trailingComment(end.Row)
+			p.lastRow = end.Row
+		}
+		p.newline()
+	}
+}
+
+func (p *printer) stmt(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.ImportStatement:
+		p.write("im ")
+		if s.Name != nil {
+			p.write(s.Name.Value + " ")
+		}
+		p.write(quote(s.Path.Value))
+	case *ast.AssignmentStatement:
+		p.expr(s.Left(), parser.LOWEST)
+		if s.Type != nil {
+			p.write(": ")
+			p.typ(s.Type)
+		}
+		p.write(" " + s.Operator + "= ")
+		p.expr(s.Value, parser.LOWEST)
+	case *ast.PatternAssignmentStatement:
+		for i, t := range s.Targets {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.pattern(t)
+		}
+		p.write(" = ")
+		p.exprList(s.Values)
+	case *ast.ExpressionStatement:
+		p.expr(s.Expression, parser.LOWEST)
+	case *ast.BlockStatement:
+		p.block(s)
+	case *ast.ForStatement:
+		p.write("l " + s.Variable.Value + " in ")
+		p.header(s.Iterable)
+		p.write(" ")
+		p.block(s.Body)
+	case *ast.TypeDeclaration:
+		p.write("t " + s.Name.Value + " {")
+		for i, f := range s.Fields {
+			if i > 0 {
+				p.write(",")
+			}
+			p.write(" " + f.Name.Value)
+			if f.Type != nil {
+				p.write(": ")
+				p.typ(f.Type)
+			}
+		}
+		if len(s.Fields) > 0 {
+			p.write(" ")
+		}
+		p.write("}")
+	}
+}
+
+func (p *printer) block(b *ast.BlockStatement) {
+	rbrace := b.Rbrace.Pos()
+	if len(b.Statements) == 0 && !p.hasCommentsBefore(rbrace) {
+		p.write("{}")
+		return
+	}
+	p.write("{")
+	p.newline()
+	p.indent++
+	p.lastRow = -1
+	defer p.enclose(rbrace)()
+	p.stmts(b.Statements)
+	p.commentsBefore(rbrace)
+	p.indent--
+	p.write("}")
+}
+
+// enclose sets the closing brace for the block or match being printed and
+// returns a func restoring the previous one.
+func (p *printer) enclose(closer tokens.Position) func() {
+	prev := p.closer
+	p.closer = closer
+	return func() { p.closer = prev }
+}
+
+// hasPos reports whether end is the end of a node from the source, rather
+// than of one built without positions.
+func hasPos(end tokens.Position) bool {
+	return end != tokens.Position{}
+}
+
+func (p *printer) hasCommentsBefore(pos tokens.Position) bool {
+	return len(p.comments) > 0 && p.comments[0].Pos().Before(pos)
+}
+
+// header prints the subject of a match or the iterable of a loop, where a
+// record literal would be mistaken for the opening brace.
+func (p *printer) header(e ast.Expression) {
+	hasRecordLit := false
+	ast.Inspect(e, func(n ast.Node) bool {
+		if _, ok := n.(*ast.RecordLiteral); ok {
+			hasRecordLit = true
+		}
+		return !hasRecordLit
+	})
+	if hasRecordLit {
+		p.write("(")
+		p.expr(e, parser.LOWEST)
+		p.write(")")
+		return
+	}
+	p.expr(e, parser.LOWEST)
+}
+
+func (p *printer) exprList(list []ast.Expression) {
+	for i, e := range list {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expr(e, parser.LOWEST)
+	}
+}
+
+// expr prints e, in parentheses if it binds less tightly than prec.
+func (p *printer) expr(e ast.Expression, prec int) {
+	if p.precedence(e) < prec {
+		p.write("(")
+		defer p.write(")")
+	}
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.IntLiteral:
+		p.write(e.String())
+	case *ast.Boolean:
+		if e.Value {
+			p.write(string(tokens.TRUE))
+		} else {
+			p.write(string(tokens.FALSE))
+		}
+	case *ast.StringLiteral:
+		p.write(quote(e.Value))
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		if r, _ := utf8.DecodeLastRuneInString(e.Operator); unicode.IsLetter(r) {
+			p.write(" ")
+		}
+		p.expr(e.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		prec := p.precedence(e)
+		left, right := prec, prec+1
+		if p.grammar.RightAssoc(tokens.TokenType(e.Operator)) {
+			left, right = prec+1, prec
+		}
+		p.expr(e.Left, left)
+		p.write(" " + e.Operator)
+		if !p.hasCommentsBefore(e.Right.Pos()) {
+			p.write(" ")
+			p.expr(e.Right, right)
+			break
+		}
+		// Comments between the operator and its right operand stay there,
+		// with the operand on the line after them.
+		p.indent++
+		p.trailingComment(e.Token.Row)
+		p.newline()
+		p.commentLines(e.Right.Pos())
+		p.expr(e.Right, right)
+		p.indent--
+	case *ast.PipeExpression:
+		p.expr(e.Left, parser.PIPE)
+		p.write(" |> ")
+		p.expr(e.Right, parser.PIPE+1)
+	case *ast.RangeExpression:
+		if e.Low != nil {
+			p.expr(e.Low, parser.RANGE+1)
+		}
+		if e.Inclusive {
+			p.write(string(tokens.RANGE_INCL))
+		} else {
+			p.write(string(tokens.RANGE))
+		}
+		if e.High != nil {
+			p.expr(e.High, parser.RANGE+1)
+		}
+	case *ast.ArrayLiteral:
+		p.write("[")
+		p.list(exprNodes(e.Elements), e.Rbrack.Pos(), func(i int) {
+			p.expr(e.Elements[i], parser.LOWEST)
+		})
+		p.write("]")
+	case *ast.MapLiteral:
+		pairs := make([]ast.Node, len(e.Pairs))
+		for i, pair := range e.Pairs {
+			pairs[i] = pair
+		}
+		p.write("{")
+		p.list(pairs, e.Rbrace.Pos(), func(i int) {
+			pair := e.Pairs[i]
+			if pair.Shorthand {
+				p.expr(pair.Value, parser.LOWEST)
+				return
+			}
+			p.expr(pair.Key, parser.LOWEST)
+			p.write(": ")
+			p.expr(pair.Value, parser.LOWEST)
+		})
+		p.write("}")
+	case *ast.IndexExpression:
+		p.expr(e.Left, parser.CALL)
+		p.write("[")
+		p.expr(e.Index, parser.LOWEST)
+		p.write("]")
+	case *ast.SelectorExpression:
+		p.expr(e.X, parser.CALL)
+		p.write("." + e.Sel.Value)
+	case *ast.CallExpression:
+		p.expr(e.Function, parser.CALL)
+		p.write("(")
+		p.list(exprNodes(e.Arguments), e.Rparen.Pos(), func(i int) {
+			p.expr(e.Arguments[i], parser.LOWEST)
+		})
+		p.write(")")
+	case *ast.RecordLiteral:
+		fields := make([]ast.Node, len(e.Fields))
+		for i, f := range e.Fields {
+			fields[i] = f
+		}
+		p.expr(e.Type, parser.CALL)
+		p.write("{")
+		p.list(fields, e.Rbrace.Pos(), func(i int) {
+			f := e.Fields[i]
+			p.write(f.Name.Value)
+			if !f.Shorthand {
+				p.write(": ")
+				p.expr(f.Value, parser.LOWEST)
+			}
+		})
+		p.write("}")
+	case *ast.FunctionLiteral:
+		p.write("f(")
+		for i, param := range e.Parameters {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.write(param.Name.Value)
+			if param.Type != nil {
+				p.write(": ")
+				p.typ(param.Type)
+			}
+		}
+		p.write(") ")
+		if e.ReturnType != nil {
+			p.write("-> ")
+			p.typ(e.ReturnType)
+			p.write(" ")
+		}
+		p.block(e.Body)
+	case *ast.MatchExpression:
+		p.match(e)
+	}
+}
+
+// list prints the items of a bracketed list closed at closer, using item to
+// print each one. The items are separated by commas on one line, unless
+// comments fall between them: then each item is on its own line, followed by
+// a comma, so the comments stay beside the items they describe.
+func (p *printer) list(items []ast.Node, closer tokens.Position, item func(i int)) {
+	if !p.hasCommentsBetween(items, closer) {
+		for i := range items {
+			if i > 0 {
+				p.write(", ")
+			}
+			item(i)
+		}
+		return
+	}
+	p.indent++
+	p.newline()
+	for i, n := range items {
+		p.commentLines(n.Pos())
+		item(i)
+		p.write(",")
+		if len(p.comments) > 0 && p.comments[0].Pos().Before(closer) {
+			p.trailingComment(n.End().Row)
+		}
+		p.newline()
+	}
+	p.commentLines(closer)
+	p.indent--
+}
+
+// hasCommentsBetween reports whether any of the pending comments before
+// closer are outside the items, rather than inside one of them.
+func (p *printer) hasCommentsBetween(items []ast.Node, closer tokens.Position) bool {
+	if !hasPos(closer) {
+		return false
+	}
+comments:
+	for _, c := range p.comments {
+		pos := c.Pos()
+		if !pos.Before(closer) {
+			return false
+		}
+		for _, n := range items {
+			if !pos.Before(n.Pos()) && pos.Before(n.End()) {
+				continue comments
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// commentLines prints, each on its own line, the pending comments before pos
+// inside an expression that is split over several lines.
+func (p *printer) commentLines(pos tokens.Position) {
+	for p.hasCommentsBefore(pos) {
+		p.write(strings.TrimRight(p.comments[0].Token.Literal, " \t\r"))
+		p.comments = p.comments[1:]
+		p.newline()
+	}
+}
+
+func exprNodes(list []ast.Expression) []ast.Node {
+	nodes := make([]ast.Node, len(list))
+	for i, e := range list {
+		nodes[i] = e
+	}
+	return nodes
+}
+
+func (p *printer) match(e *ast.MatchExpression) {
+	p.write("m ")
+	p.header(e.Subject)
+	rbrace := e.Rbrace.Pos()
+	if len(e.Arms) == 0 && !p.hasCommentsBefore(rbrace) {
+		p.write(" {}")
+		return
+	}
+	p.write(" {")
+	p.newline()
+	p.indent++
+	p.lastRow = -1
+	defer p.enclose(rbrace)()
+	for _, arm := range e.Arms {
+		start, end := arm.Pos(), arm.End()
+		if hasPos(end) {
+			p.commentsBefore(start)
+			p.separate(start.Row)
+		}
+		p.pattern(arm.Pattern)
+		if arm.Guard != nil {
+			p.write(" " + string(tokens.IF) + " ")
+			p.expr(arm.Guard, parser.LOWEST)
+		}
+		p.write(" -> ")
+		p.expr(arm.Body, parser.LOWEST)
+		p.write(",")
+		if hasPos(end) {
+			p.trailingComment(end.Row)
+			p.lastRow = end.Row
+		}
+		p.newline()
+	}
+	p.commentsBefore(rbrace)
+	p.indent--
+	p.write("}")
+}
+
+// precedence returns how tightly e binds as an operand.
+func (p *printer) precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return p.grammar.Precedence(tokens.TokenType(e.Operator))
+	case *ast.PipeExpression:
+		return parser.PIPE
+	case *ast.RangeExpression:
+		return parser.RANGE
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.IntLiteral:
+		if e.Value < 0 || e.Big != nil && e.Big.Sign() < 0 {
+			return parser.PREFIX
+		}
+	case *ast.CallExpression, *ast.IndexExpression, *ast.SelectorExpression, *ast.RecordLiteral:
+		return parser.CALL
+	}
+	return highest
+}
+
+func (p *printer) pattern(pat ast.Pattern) {
+	switch pat := pat.(type) {
+	case *ast.WildcardPattern:
+		p.write("_")
+	case *ast.BindingPattern:
+		p.write(pat.Name.Value)
+	case *ast.LiteralPattern:
+		p.expr(pat.Value, parser.LOWEST)
+	case *ast.ArrayPattern:
+		p.write("[")
+		for i, elem := range pat.Elements {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.pattern(elem)
+		}
+		p.write("]")
+	case *ast.MapPattern:
+		p.write("{")
+		for i, pair := range pat.Pairs {
+			if i > 0 {
+				p.write(", ")
+			}
+			if !pair.Shorthand {
+				p.expr(pair.Key, parser.LOWEST)
+				p.write(": ")
+			}
+			p.pattern(pair.Value)
+		}
+		p.write("}")
+	}
+}
+
+func (p *printer) typ(typ ast.TypeExpr) {
+	switch typ := typ.(type) {
+	case *ast.NamedType:
+		p.write(typ.Name)
+	case *ast.ListType:
+		p.write("[")
+		p.typ(typ.Elem)
+		p.write("]")
+	case *ast.MapType:
+		p.write("{")
+		p.typ(typ.Key)
+		p.write(": ")
+		p.typ(typ.Value)
+		p.write("}")
+	case *ast.FunctionType:
+		p.write("f(")
+		for i, param := range typ.Params {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.typ(param)
+		}
+		p.write(")")
+		if typ.Result != nil {
+			p.write(" -> ")
+			p.typ(typ.Result)
+		}
+	case *ast.OptionalType:
+		p.typ(typ.Elem)
+		p.write("?")
+	}
+}
+
+// quote returns s as a Brev string literal, using only the escapes the lexer
+// understands.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
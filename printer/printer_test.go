@@ -0,0 +1,231 @@
+package printer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/joerdav/brev/ast"
+	"github.com/joerdav/brev/parser"
+	"github.com/joerdav/brev/tokens"
+	"github.com/matryer/is"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{"assignment", "foo=bar", "foo = bar\n"},
+		{"infix spacing", "a+b*c", "a + b * c\n"},
+		{"redundant parens", "(a*b)+c", "a * b + c\n"},
+		{"needed parens", "(a+b)*c", "(a + b) * c\n"},
+		{"left associative", "a-(b-c)", "a - (b - c)\n"},
+		{"prefix", "-(a+b)", "-(a + b)\n"},
+		{"prefix of index", "-xs[0]", "-xs[0]\n"},
+		{"postfix of prefix", "(-x)[0]", "(-x)[0]\n"},
+		{"statements", "a=1 b=2", "a = 1\nb = 2\n"},
+		{"blank lines", "a=1\n\n\n\nb=2", "a = 1\n\nb = 2\n"},
+		{"compound assignment", "xs[0]+=1", "xs[0] += 1\n"},
+		{"typed assignment", "x:[int]?=[]", "x: [int]? = []\n"},
+		{"pattern assignment", "a,b=b,a", "a, b = b, a\n"},
+		{"collections", `{"a":[1,2],b}`, "{\"a\": [1, 2], b}\n"},
+		{"strings", `s="a\"b\\c\n"`, "s = \"a\\\"b\\\\c\\n\"\n"},
+		{"big ints", "-(18446744073709551616)+1", "-18446744073709551616 + 1\n"},
+		{"booleans", "T!=F", "T != F\n"},
+		{"ranges", "xs[1..=n+1] ys[..3]", "xs[1..=n + 1]\nys[..3]\n"},
+		{"pipes", "xs|>map(g)|>len", "xs |> map(g) |> len\n"},
+		{"function", "add=f(a:int,b)->int{a+b}", "add = f(a: int, b) -> int {\n\ta + b\n}\n"},
+		{"empty function", "g=f(){}", "g = f() {}\n"},
+		{"function type", "h:f(int)->str=g", "h: f(int) -> str = g\n"},
+		{"call", "g ( 1 , 2 )", "g(1, 2)\n"},
+		{"records", "t P{x:int,y} p=P{x:1,y}", "t P { x: int, y }\np = P{x: 1, y}\n"},
+		{"empty record", "t P{}", "t P {}\n"},
+		{"selector", "p.x.y", "p.x.y\n"},
+		{"imports", `im "lib/a" im b "lib/b"`, "im \"lib/a\"\nim b \"lib/b\"\n"},
+		{"for", "l n in 1..10{total+=n}", "l n in 1..10 {\n\ttotal += n\n}\n"},
+		{"match", `m x{0->"zero",[a,b] i a>b->a,{name,"k":v}->v,_->-1}`,
+			"m x {\n\t0 -> \"zero\",\n\t[a, b] i a > b -> a,\n\t{name, \"k\": v} -> v,\n\t_ -> -1,\n}\n"},
+		{"match record subject", "m (P{x}) {_->1}", "m (P{x}) {\n\t_ -> 1,\n}\n"},
+		{"nested blocks", "g=f(){l x in xs{h=f(){x}}}", "g = f() {\n\tl x in xs {\n\t\th = f() {\n\t\t\tx\n\t\t}\n\t}\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			out, err := Source([]byte(tt.input))
+			is.NoErr(err)
+			is.Equal(tt.expected, string(out))
+		})
+	}
+}
+
+func TestSourceComments(t *testing.T) {
+	input := `// header
+a=1 // one
+
+
+// before b
+b=f(){
+// inside
+x
+// end of block
+} // after block
+m b { // subject
+  // first
+  0 -> 1, // zero
+  _ -> 2
+}
+// footer
+`
+	expected := `// header
+a = 1 // one
+
+// before b
+b = f() {
+	// inside
+	x
+	// end of block
+} // after block
+m b {
+	// subject
+	// first
+	0 -> 1, // zero
+	_ -> 2,
+}
+// footer
+`
+	is := is.New(t)
+	out, err := Source([]byte(input))
+	is.NoErr(err)
+	is.Equal(expected, string(out))
+}
+
+func TestSourceCommentsInExpressions(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{"list", "z = [\n 1, // a\n 2,\n]", "z = [\n\t1, // a\n\t2,\n]\n"},
+		{"list leading", "z = [1,\n// b\n2 // c\n]", "z = [\n\t1,\n\t// b\n\t2, // c\n]\n"},
+		{"list end", "z = [1, 2\n// end\n] // after", "z = [\n\t1,\n\t2,\n\t// end\n] // after\n"},
+		{"map", "d = {\"a\": 1, // a\nb}", "d = {\n\t\"a\": 1, // a\n\tb,\n}\n"},
+		{"call", "g(1, // one\n2)", "g(\n\t1, // one\n\t2,\n)\n"},
+		{"record", "p = P{x: 1, // x\ny}", "p = P{\n\tx: 1, // x\n\ty,\n}\n"},
+		{"infix", "x = 1 +\n // mid\n 2", "x = 1 +\n\t// mid\n\t2\n"},
+		{"infix trailing", "x = 1 + // one\n 2", "x = 1 + // one\n\t2\n"},
+		{"comment inside item", "z = [f() {\n// in\nx\n}, 2]", "z = [f() {\n\t// in\n\tx\n}, 2]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			out, err := Source([]byte(tt.input))
+			is.NoErr(err)
+			is.Equal(tt.expected, string(out))
+			again, err := Source(out)
+			is.NoErr(err)
+			is.Equal(string(out), string(again))
+		})
+	}
+}
+
+func TestSourceCustomOperators(t *testing.T) {
+	opts := []parser.Option{
+		parser.WithInfix("pow", parser.PRODUCT+1, parser.RightAssoc),
+		parser.WithInfix("or", parser.LOWEST+1, parser.LeftAssoc),
+	}
+	tests := []struct {
+		input, expected string
+	}{
+		{"a*(b pow c)", "a * b pow c\n"},
+		{"(a*b) pow c", "(a * b) pow c\n"},
+		{"a pow (b pow c)", "a pow b pow c\n"},
+		{"(a pow b) pow c", "(a pow b) pow c\n"},
+		{"(a or b) == c", "(a or b) == c\n"},
+		{"a or (b == c)", "a or b == c\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			is := is.New(t)
+			out, err := Source([]byte(tt.input), opts...)
+			is.NoErr(err)
+			is.Equal(tt.expected, string(out))
+		})
+	}
+}
+
+func TestSourceIsStable(t *testing.T) {
+	input := `im util "lib/util"
+t Point{x:int,y:int}
+// distance squared
+d2=f(a:Point,b:Point)->int{
+dx=a.x-b.x dy=(a.y-b.y)
+dx*dx+dy*dy // no sqrt
+}
+l p in [Point{x:1,y:2},Point{x:3,y:4}] {
+total+=d2(p,origin) |> abs
+}
+label = m total { 0 -> "none", n i n > 10 -> "far", _ -> "near" }
+`
+	is := is.New(t)
+	first, err := Source([]byte(input))
+	is.NoErr(err)
+	second, err := Source(first)
+	is.NoErr(err)
+	is.Equal(string(first), string(second))
+
+	want, err := parser.ParseString(input)
+	is.NoErr(err)
+	got, err := parser.ParseString(string(first))
+	is.NoErr(err)
+	is.Equal(want.String(), got.String())
+}
+
+func TestSourceErrors(t *testing.T) {
+	is := is.New(t)
+	_, err := Source([]byte("a = "))
+	is.True(err != nil)
+	is.True(parser.IsIncomplete(err))
+}
+
+func TestFprintNode(t *testing.T) {
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{
+			&ast.InfixExpression{
+				Operator: "*",
+				Left: &ast.InfixExpression{
+					Operator: "+",
+					Left:     &ast.Identifier{Value: "a"},
+					Right:    &ast.Identifier{Value: "b"},
+				},
+				Right: &ast.IntLiteral{Value: 2},
+			},
+			"(a + b) * 2",
+		},
+		{
+			&ast.AssignmentStatement{
+				Token: tokens.Token{Literal: "="},
+				Name:  &ast.Identifier{Value: "foo"},
+				Value: &ast.Identifier{Value: "bar"},
+			},
+			"foo = bar",
+		},
+		{&ast.ListType{Elem: &ast.NamedType{Name: "int"}}, "[int]"},
+		{&ast.ArrayPattern{Elements: []ast.Pattern{&ast.WildcardPattern{}}}, "[_]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			is := is.New(t)
+			var buf bytes.Buffer
+			is.NoErr(Fprint(&buf, tt.node))
+			is.Equal(tt.expected, buf.String())
+		})
+	}
+}
+
+func TestFprintUnsupported(t *testing.T) {
+	is := is.New(t)
+	err := Fprint(&bytes.Buffer{}, &ast.Package{})
+	is.True(err != nil)
+	is.True(strings.Contains(err.Error(), "*ast.Package"))
+}
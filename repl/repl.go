@@ -6,21 +6,37 @@ import (
 	"io"
 	"strings"
 
-	"github.com/joerdav/brev/lexer"
-	"github.com/joerdav/brev/tokens"
+	"github.com/joerdav/brev/parser"
 )
 
-const prompt = ">> "
+const (
+	prompt       = ">> "
+	continuation = ".. "
+)
 
+// Start reads statements from in and writes the result of each to out.
+// Input that is incomplete, such as an unclosed `{`, is continued on the next
+// line. An empty continuation line gives up and reports the errors.
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
-	fmt.Fprintf(out, prompt)
+	var src strings.Builder
+	fmt.Fprint(out, prompt)
 	for scanner.Scan() {
 		line := scanner.Text()
-		l := lexer.NewLexer(strings.NewReader(line))
-		for tok := l.NextToken(); tok.Type != tokens.EOF; tok = l.NextToken() {
-			fmt.Fprintf(out, "%#v\n", tok)
+		continuing := src.Len() > 0
+		src.WriteString(line)
+		src.WriteString("\n")
+		program, err := parser.ParseString(src.String())
+		if parser.IsIncomplete(err) && !(continuing && strings.TrimSpace(line) == "") {
+			fmt.Fprint(out, continuation)
+			continue
+		}
+		src.Reset()
+		if err != nil {
+			fmt.Fprintln(out, err)
+		} else if len(program.Statements) > 0 {
+			fmt.Fprintln(out, program.String())
 		}
-		fmt.Fprintf(out, prompt)
+		fmt.Fprint(out, prompt)
 	}
 }
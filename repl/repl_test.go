@@ -0,0 +1,29 @@
+package repl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestStartContinuesIncompleteInput(t *testing.T) {
+	is := is.New(t)
+	in := strings.NewReader("add = f(a, b) {\n\ta + b\n}\nx = 1 +\n2\n")
+	var out strings.Builder
+	Start(in, &out)
+	expected := ">> .. .. add = f(a, b) { a+b }\n>> .. x = 1+2\n>> "
+	is.Equal(out.String(), expected)
+}
+
+func TestStartReportsErrors(t *testing.T) {
+	is := is.New(t)
+	in := strings.NewReader("x = )\ny = (1 +\n\nz\n")
+	var out strings.Builder
+	Start(in, &out)
+	expected := ">> prefix ) not recognised (line: 0 col: 4)\n" +
+		">> .. prefix EOF not recognised (line: 2 col: 0)\n" +
+		"expected next token ), got EOF (line: 2 col: 0)\n" +
+		">> z\n>> "
+	is.Equal(out.String(), expected)
+}
@@ -0,0 +1,46 @@
+package tokens
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Position is a location in the source, rows and columns count from 0.
+type Position struct {
+	File     string
+	Row, Col int
+}
+
+func (p Position) String() string {
+	if p.File != "" {
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Row, p.Col)
+	}
+	return fmt.Sprintf("%d:%d", p.Row, p.Col)
+}
+
+// Before reports whether p comes before q in the same file.
+func (p Position) Before(q Position) bool {
+	return p.Row < q.Row || p.Row == q.Row && p.Col < q.Col
+}
+
+// Pos returns the position of the first character of the token.
+func (t Token) Pos() Position {
+	return Position{File: t.File, Row: t.Row, Col: t.Col}
+}
+
+// End returns the position immediately after the token, following the rows
+// of a string written over several lines.
+func (t Token) End() Position {
+	if t.Raw == "" {
+		return Position{File: t.File, Row: t.Row, Col: t.Col + utf8.RuneCountInString(t.Literal)}
+	}
+	end := t.Pos()
+	for _, r := range t.Raw {
+		end.Col++
+		if r == '\n' || r == '\r' {
+			end.Row++
+			end.Col = 0
+		}
+	}
+	return end
+}
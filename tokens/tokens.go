@@ -6,6 +6,12 @@ type (
 		Type     TokenType
 		Literal  string
 		Col, Row int
+		// File is the name of the source file, empty when the source isn't
+		// a file.
+		File string
+		// Raw is the source text of a string, with its quotes and escapes,
+		// empty for tokens whose literal is their source text.
+		Raw string
 	}
 )
 
@@ -15,12 +21,26 @@ func (tt TokenType) GoString() string {
 		return "tokens.IDENT"
 	case ASSIGN:
 		return "tokens.ASSIGN"
+	case ADD_ASSIGN:
+		return "tokens.ADD_ASSIGN"
+	case SUB_ASSIGN:
+		return "tokens.SUB_ASSIGN"
+	case ASTERISK_ASSIGN:
+		return "tokens.ASTERISK_ASSIGN"
+	case SLASH_ASSIGN:
+		return "tokens.SLASH_ASSIGN"
+	case PERCENT_ASSIGN:
+		return "tokens.PERCENT_ASSIGN"
 	case NUMBER:
 		return "tokens.NUMBER"
+	case STRING:
+		return "tokens.STRING"
 	case EOF:
 		return "tokens.EOF"
 	case ILLEGAL:
 		return "tokens.ILLEGAL"
+	case COMMENT:
+		return "tokens.COMMENT"
 	case NE:
 		return "tokens.NE"
 	case EQ:
@@ -51,6 +71,24 @@ func (tt TokenType) GoString() string {
 		return "tokens.RBRC"
 	case COMMA:
 		return "tokens.COMMA"
+	case LSQB:
+		return "tokens.LSQB"
+	case RSQB:
+		return "tokens.RSQB"
+	case COLON:
+		return "tokens.COLON"
+	case ARROW:
+		return "tokens.ARROW"
+	case QUESTION:
+		return "tokens.QUESTION"
+	case DOT:
+		return "tokens.DOT"
+	case PIPE:
+		return "tokens.PIPE"
+	case RANGE:
+		return "tokens.RANGE"
+	case RANGE_INCL:
+		return "tokens.RANGE_INCL"
 	case FUNCTION:
 		return "tokens.FUNCTION"
 	case IF:
@@ -63,6 +101,16 @@ func (tt TokenType) GoString() string {
 		return "tokens.TRUE"
 	case FALSE:
 		return "tokens.FALSE"
+	case MATCH:
+		return "tokens.MATCH"
+	case LOOP:
+		return "tokens.LOOP"
+	case IN:
+		return "tokens.IN"
+	case TYPE:
+		return "tokens.TYPE"
+	case IMPORT:
+		return "tokens.IMPORT"
 	default:
 		return string(tt)
 	}
@@ -72,8 +120,16 @@ const (
 	IDENT   TokenType = "ident"
 	ASSIGN            = "="
 	NUMBER            = "number"
+	STRING            = "string"
 	EOF               = "EOF"
 	ILLEGAL           = "illegal"
+	COMMENT           = "comment"
+
+	ADD_ASSIGN      = "+="
+	SUB_ASSIGN      = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+	PERCENT_ASSIGN  = "%="
 
 	NE       = "!="
 	EQ       = "=="
@@ -90,6 +146,16 @@ const (
 	LBRC     = "{"
 	RBRC     = "}"
 	COMMA    = ","
+	LSQB     = "["
+	RSQB     = "]"
+	COLON    = ":"
+	ARROW    = "->"
+	QUESTION = "?"
+	DOT      = "."
+	PIPE     = "|>"
+
+	RANGE      = ".."
+	RANGE_INCL = "..="
 
 	// Keywords
 	FUNCTION = "f"
@@ -98,4 +164,9 @@ const (
 	ELIF     = "ei"
 	TRUE     = "T"
 	FALSE    = "F"
+	MATCH    = "m"
+	LOOP     = "l"
+	IN       = "in"
+	TYPE     = "t"
+	IMPORT   = "im"
 )